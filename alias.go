@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// redirectHTML returns an HTML page that redirects to url.
+func redirectHTML(url string) string {
+	u := template.HTMLEscapeString(url)
+	return `<!DOCTYPE html><html><head><meta charset="utf-8">` +
+		`<title>` + u + `</title>` +
+		`<link rel="canonical" href="` + u + `">` +
+		`<meta http-equiv="refresh" content="0; url=` + u + `">` +
+		`</head></html>` + "\n"
+}
+
+// writeAliases writes a redirect page at build/<alias>/index.html for
+// each alias of pages, which are keyed by source path. The redirects
+// point to joinURL(baseURL, p.Path). It returns the names of the
+// redirect pages.
+//
+// It is an error for an alias to be the path of a page, the path of
+// another alias, or the path of an index.html file in src.
+func writeAliases(src, build, baseURL string, pages map[string]*Page) ([]string, error) {
+	owner := make(map[string]*Page) // Path to page.
+	for _, p := range pages {
+		owner[p.Path] = p
+	}
+
+	// Sorted for deterministic errors.
+	var srcs []string
+	for s := range pages {
+		srcs = append(srcs, s)
+	}
+	sort.Strings(srcs)
+
+	aliases := make(map[string]*Page)
+	for _, s := range srcs {
+		p := pages[s]
+		for _, a := range p.Aliases {
+			a = path.Clean("/" + strings.Trim(a, "/"))
+			if other, ok := owner[a]; ok {
+				return nil, &fileError{s, fmt.Errorf("alias %q is the path of page %q", a, other.Path)}
+			}
+			if other, ok := aliases[a]; ok {
+				return nil, &fileError{s, fmt.Errorf("alias %q is also an alias of page %q", a, other.Path)}
+			}
+			exists, err := pathExists(filepath.Join(src, filepath.FromSlash(a), "index.html"))
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				return nil, &fileError{s, fmt.Errorf("alias %q is the path of an index.html file", a)}
+			}
+			aliases[a] = p
+		}
+	}
+
+	var names []string
+	for a, p := range aliases {
+		name := filepath.Join(build, filepath.FromSlash(a), "index.html")
+		if _, err := writeIfChanged(name, []byte(redirectHTML(joinURL(baseURL, p.Path)))); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildAliases(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":   `{{ .Current.Title }}`,
+		"src/blog/hello.md": "+++\ntitle = \"hello\"\naliases = [\"/old/hello\", \"hi/\"]\n+++\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{BaseURL: "https://example.com"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := redirectHTML("https://example.com/blog/hello")
+	for _, name := range []string{"build/old/hello/index.html", "build/hi/index.html"} {
+		if got := readFile(t, name); got != expected {
+			t.Fatalf("%s: got %q, expected %q", name, got, expected)
+		}
+	}
+	for _, s := range []string{
+		`<link rel="canonical" href="https://example.com/blog/hello">`,
+		`<meta http-equiv="refresh" content="0; url=https://example.com/blog/hello">`,
+	} {
+		if !strings.Contains(expected, s) {
+			t.Fatalf("redirectHTML: %q does not contain %q", expected, s)
+		}
+	}
+}
+
+func TestBuildAliasCollision(t *testing.T) {
+	testcases := []struct {
+		files    map[string]string
+		expected string // Substring of the error.
+	}{
+		{
+			map[string]string{
+				"src/a.md": "+++\naliases = [\"/b\"]\n+++\n",
+				"src/b.md": "+++\ntitle = \"b\"\n+++\n",
+			},
+			`alias "/b" is the path of page "/b"`,
+		},
+		{
+			map[string]string{
+				"src/a.md": "+++\naliases = [\"/old\"]\n+++\n",
+				"src/b.md": "+++\naliases = [\"/old/\"]\n+++\n",
+			},
+			`alias "/old" is also an alias of page "/a"`,
+		},
+		{
+			map[string]string{
+				"src/a.md":            "+++\naliases = [\"/docs\"]\n+++\n",
+				"src/docs/index.html": "",
+			},
+			`alias "/docs" is the path of an index.html file`,
+		},
+	}
+
+	for _, tc := range testcases {
+		dir := t.TempDir()
+		tc.files["src/layout.tmpl"] = ""
+		writeTree(t, dir, tc.files)
+		t.Chdir(dir)
+
+		err := (&Build{}).Run()
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Fatalf("Run: got error %v, expected it to contain %q", err, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// ArchetypesDir is the name of the source directory with templates for
+// the markdown files created by "batsman new". The archetype for a file
+// in a section, such as "blog/post.md", is "blog.md"; files in other
+// sections, or in none, use "default.md".
+const ArchetypesDir = "_archetypes"
+
+// archetypeData is the data available to archetype templates.
+type archetypeData struct {
+	Title string // Title of the new page.
+	Date  string // Current time, in the default front matter time format.
+	Draft bool   // Whether the page is a draft.
+}
+
+// findArchetype returns the archetype in src for the markdown file out,
+// or the empty string if there is none. The section of out is its first
+// path element relative to src, or relative to the working directory if
+// out is not in src.
+func findArchetype(src, out string) (string, error) {
+	dir := filepath.Join(src, ArchetypesDir)
+	var candidates []string
+	if out != "" {
+		rel, err := filepath.Rel(src, out)
+		if err != nil || escapes(rel) {
+			rel = filepath.Clean(out)
+		}
+		if i := strings.IndexRune(rel, filepath.Separator); i > 0 {
+			candidates = append(candidates, filepath.Join(dir, rel[:i]+".md"))
+		}
+	}
+	candidates = append(candidates, filepath.Join(dir, "default.md"))
+
+	for _, p := range candidates {
+		ok, err := pathExists(p)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return p, nil
+		}
+	}
+	return "", nil
+}
+
+// renderArchetype executes the archetype template at p with data.
+func renderArchetype(p string, data archetypeData) ([]byte, error) {
+	t, err := template.ParseFiles(p)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
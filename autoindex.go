@@ -0,0 +1,80 @@
+package main
+
+import (
+	"html/template"
+	"path/filepath"
+	"sort"
+
+	"github.com/tdewolff/minify"
+)
+
+// DefaultsDir is the directory, relative to the source directory, of
+// templates that replace the built-in ones, such as ListTemplate.
+const DefaultsDir = "_default"
+
+// ListTemplate is the name of the template in DefaultsDir used for the
+// directory listings generated by Build.AutoIndex.
+const ListTemplate = "list.tmpl"
+
+// defaultList is the built-in template for directory listings.
+const defaultList = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Index</title>
+</head>
+<body>
+<ul>
+{{- range .Dir }}
+<li><a href="{{ .Path }}">{{ .Title }}</a> <time datetime="{{ formatTime .Time "rfc3339" }}">{{ formatTime .Time "date" }}</time></li>
+{{- end }}
+</ul>
+</body>
+</html>
+`
+
+// listTemplate returns the template for directory listings: the
+// ListTemplate file in src, if it exists, or the built-in template.
+func listTemplate(src string, partials *template.Template) (*template.Template, error) {
+	name := filepath.Join(src, DefaultsDir, ListTemplate)
+	exists, err := pathExists(name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return parseTemplate(partials, name)
+	}
+	t, err := partials.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return t.New(ListTemplate).Parse(defaultList)
+}
+
+// writeAutoIndexes writes an index.html listing the pages of each
+// directory in dirPages that has no index.html of its own in src.
+func (b *Build) writeAutoIndexes(mf *minify.M, src, build string, tmpl *template.Template, args TemplateArgs, dirPages map[string][]*Page) error {
+	dirs := make([]string, 0, len(dirPages))
+	for dir := range dirPages {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		exists, err := pathExists(filepath.Join(src, dir, "index.html"))
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		name := filepath.Join(build, dir, "index.html")
+		args.Dir = dirPages[dir]
+		b.logf("list", filepath.Join(src, dir), name)
+		if err := b.executeHTML(mf, tmpl, name, args); err != nil {
+			return &fileError{filepath.Join(src, dir), err}
+		}
+	}
+	return nil
+}
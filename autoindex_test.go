@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildAutoIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":      `{{ .Current.Title }}`,
+		"src/index.html":       `home`,
+		"src/about.md":         "+++\ntitle = \"about\"\n+++\n",
+		"src/blog/a.md":        "+++\ntitle = \"A\"\ntime = \"2016-01-01\"\n+++\n",
+		"src/blog/b.md":        "+++\ntitle = \"B & C\"\ntime = \"2016-01-02\"\n+++\n",
+		"src/notes/c.md":       "+++\ntitle = \"c\"\n+++\n",
+		"src/notes/index.html": `notes`,
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{NoMinify: true, AutoIndex: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readFile(t, "build/blog/index.html")
+	expected := `<li><a href="/blog/b">B &amp; C</a> <time datetime="2016-01-02T00:00:00Z">2016-01-02</time></li>
+<li><a href="/blog/a">A</a> <time datetime="2016-01-01T00:00:00Z">2016-01-01</time></li>
+</ul>`
+	if !strings.Contains(got, expected) {
+		t.Fatalf("build/blog/index.html: got %q, expected it to contain %q", got, expected)
+	}
+	for name, expected := range map[string]string{"build/index.html": "home", "build/notes/index.html": "notes"} {
+		if got := readFile(t, name); got != expected {
+			t.Fatalf("%s: got %q, expected %q", name, got, expected)
+		}
+	}
+
+	// A list template in the source directory replaces the built-in one.
+	writeTree(t, dir, map[string]string{
+		"src/_default/list.tmpl": `{{ range .Dir }}{{ .Title }};{{ end }}`,
+	})
+	if err := (&Build{NoMinify: true, AutoIndex: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := readFile(t, "build/blog/index.html"), "B &amp; C;A;"; got != expected {
+		t.Fatalf("build/blog/index.html: got %q, expected %q", got, expected)
+	}
+	if _, err := os.Stat(filepath.Join("build", DefaultsDir)); !os.IsNotExist(err) {
+		t.Fatalf("%s: expected not to be copied, got err %v", DefaultsDir, err)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	texttemplate "text/template"
@@ -27,23 +29,273 @@ var blackfridayHTMLFlags = blackfriday.HTML_USE_XHTML |
 	blackfriday.HTML_USE_SMARTYPANTS |
 	blackfriday.HTML_SMARTYPANTS_FRACTIONS |
 	blackfriday.HTML_SMARTYPANTS_DASHES |
-	blackfriday.HTML_SMARTYPANTS_LATEX_DASHES
-
-var blackfridayExtensions = blackfriday.EXTENSION_NO_INTRA_EMPHASIS |
-	blackfriday.EXTENSION_TABLES |
-	blackfriday.EXTENSION_FENCED_CODE |
-	blackfriday.EXTENSION_AUTOLINK |
-	blackfriday.EXTENSION_STRIKETHROUGH |
-	blackfriday.EXTENSION_SPACE_HEADERS |
-	blackfriday.EXTENSION_HEADER_IDS |
-	blackfriday.EXTENSION_BACKSLASH_LINE_BREAK |
-	blackfriday.EXTENSION_DEFINITION_LISTS |
-	blackfriday.EXTENSION_AUTO_HEADER_IDS
+	blackfriday.HTML_SMARTYPANTS_LATEX_DASHES |
+	blackfriday.HTML_FOOTNOTE_RETURN_LINKS
 
 type Build struct {
 	// Funcs is the list of plugins applied
-	// on markdown files.
+	// on markdown files. If nil, DefaultFuncs() is used.
 	Funcs texttemplate.FuncMap
+
+	// Jobs is the maximum number of files processed
+	// concurrently. If zero, runtime.NumCPU() is used.
+	Jobs int
+
+	Src string // Source directory. If empty, "src" is used.
+	Out string // Output directory. If empty, "build" is used.
+
+	// Templates caches parsed templates across runs, such as the
+	// rebuilds of "serve -watch". If nil, templates are parsed in
+	// every run.
+	Templates *TemplateCache
+
+	stamps map[string]time.Time // Source modification times at the last successful run.
+	counts pageCounts           // Pages built and skipped in the last successful run.
+	only   map[string]bool      // If non-nil, the only source files to generate.
+
+	page    string    // If non-empty, the only markdown file to render, for RenderPage.
+	pageOut io.Writer // Writer that RenderPage renders page to.
+
+	// BaseURL is the base URL of the site, such as
+	// "https://example.com". It is used to make absolute URLs.
+	BaseURL string
+
+	// BasePath is the path the site is deployed under, such as
+	// "/blog". It prefixes the Path of pages, the URLs of
+	// paginated pages, and the paths made by the "relURL" template
+	// function.
+	BasePath string
+
+	Title       string // Title of the site.
+	Description string // Description of the site.
+	Author      string // Author of the site.
+
+	// FrontMatterSep is the separator between the front matter and
+	// content of markdown files. If empty, FrontMatterSep is used.
+	FrontMatterSep string
+
+	// Drafts indicates whether to include markdown files
+	// marked as drafts in front matter.
+	Drafts bool
+
+	// DraftsTo is a directory, relative to the output directory,
+	// that drafts are generated into. Drafts are then excluded
+	// from Dir, All, and other pages' Prev and Next, so that they
+	// are only reachable by their path. If empty, drafts are
+	// handled according to Drafts.
+	DraftsTo string
+
+	// Expired indicates whether to include markdown files whose
+	// expiry time in front matter has passed.
+	Expired bool
+
+	// SearchIndex indicates whether to write a JSON search
+	// index of pages to SearchIndexFile in the output directory.
+	SearchIndex bool
+
+	// RSS, JSONFeed, and Atom indicate whether to write an RSS
+	// feed of pages to FeedFile, a JSON feed to JSONFeedFile, and
+	// an Atom feed to AtomFile in the output directory. If
+	// FeedFullContent is true, the feeds contain the full content
+	// of pages rather than their summaries.
+	RSS             bool
+	JSONFeed        bool
+	Atom            bool
+	FeedFullContent bool
+
+	// PageSize is the number of markdown pages per page given to
+	// "index.html" templates. If zero, pagination is disabled.
+	PageSize int
+
+	// NoMinify indicates whether to write generated HTML and
+	// CSS, JS, and SVG files verbatim instead of minifying them.
+	NoMinify bool
+
+	// NoMinifyExts is the set of extensions, such as ".svg", of
+	// files that are written verbatim even if NoMinify is false.
+	// ".html" applies to all generated HTML.
+	NoMinifyExts map[string]bool
+
+	// Robots indicates whether to write a default robots.txt to
+	// the output directory if the source directory has none.
+	Robots bool
+
+	// Sitemap indicates whether to write a sitemap of the markdown
+	// pages to SitemapFile in the output directory. It requires
+	// BaseURL.
+	Sitemap bool
+
+	// Compress indicates whether to write a gzip-compressed copy,
+	// with ".gz" appended to the name, of each generated HTML, CSS,
+	// JS, SVG, JSON, XML, and text file.
+	Compress bool
+
+	// OptimizeImages indicates whether to re-encode PNG and JPEG
+	// files to reduce their size.
+	OptimizeImages bool
+
+	// ImageQuality is the JPEG quality, 1-100, used when optimizing
+	// images. If zero, DefaultImageQuality is used.
+	ImageQuality int
+
+	// Fingerprint indicates whether to add a hash of the contents
+	// to the names of CSS, JS, and SVG files. Templates
+	// refer to the names using the "fingerprint" function.
+	Fingerprint bool
+
+	// HeadingAnchors indicates whether to add a "#" link to
+	// h2-h6 headings in markdown files.
+	HeadingAnchors bool
+
+	// AutoIndex indicates whether to generate an index.html listing
+	// the markdown pages of each directory that has none, using
+	// "_default/list.tmpl" in the source directory if it exists.
+	AutoIndex bool
+
+	// Emoji indicates whether to replace emoji shortcodes, such as
+	// ":rocket:", in markdown files with the emoji.
+	Emoji bool
+
+	// MarkdownExtensions is the markdown extensions to use, a
+	// combination of blackfriday's EXTENSION_* values and
+	// ExtensionTaskLists. If zero, DefaultMarkdownExtensions is used.
+	MarkdownExtensions int
+
+	// Mermaid indicates whether to convert "mermaid" fenced code
+	// blocks in markdown files to diagrams, setting HasMermaid on
+	// the pages that have them.
+	Mermaid bool
+
+	// Math indicates whether to keep "$$...$$" and "$...$" math in
+	// markdown files as written, for KaTeX to render in the browser,
+	// setting HasMath on the pages that have it.
+	Math bool
+
+	// CheckLinks indicates whether to report root-relative links
+	// in generated HTML files that refer to no generated file.
+	CheckLinks bool
+
+	// SizeBudget is the size in bytes above which a generated
+	// HTML file is reported with a warning on stderr. If zero,
+	// sizes are not checked.
+	SizeBudget int
+
+	// Manifest indicates whether to write ManifestFile, listing
+	// each generated file with its source, size, and SHA-256 hash,
+	// to the output directory.
+	Manifest bool
+
+	// Verbose indicates whether to log the action taken for
+	// each file to stderr.
+	Verbose bool
+
+	// Profile indicates whether to print the counts and durations
+	// of the phases of the build to stderr.
+	Profile bool
+
+	stats *buildStats // Stats of the last run, if Profile is true.
+
+	logMx sync.Mutex // Serializes verbose log lines.
+
+	overBudgetMx sync.Mutex
+	overBudget   int // Number of HTML files over SizeBudget in the current run.
+
+	sourcesMx sync.Mutex
+	sources   map[string]string // Source file, or "", of each generated file, for Manifest.
+
+	draftsMx sync.Mutex
+	drafts   map[string]bool // Paths of draft pages in the last run.
+}
+
+func (b *Build) srcDir() string {
+	if b.Src != "" {
+		return b.Src
+	}
+	return "src"
+}
+
+func (b *Build) outDir() string {
+	if b.Out != "" {
+		return b.Out
+	}
+	return "build"
+}
+
+// basePath returns BasePath as "" or a path with a leading slash and
+// no trailing slash, such as "/blog".
+func (b *Build) basePath() string {
+	p := path.Clean("/" + b.BasePath)
+	if p == "/" {
+		return ""
+	}
+	return p
+}
+
+// isDraft reports whether the HTTP path p, such as "/blog/post/" or
+// "/blog/post/index.html", is the path of a draft page in the last run.
+// It is safe to call during a run.
+func (b *Build) isDraft(p string) bool {
+	p = strings.TrimSuffix(path.Clean("/"+p), "/index.html")
+	b.draftsMx.Lock()
+	defer b.draftsMx.Unlock()
+	return b.drafts[p]
+}
+
+// generated records the named file as generated in the current run,
+// without a source file, for the manifest. A source recorded by logf
+// is kept.
+func (b *Build) generated(name string) {
+	if !b.Manifest {
+		return
+	}
+	b.sourcesMx.Lock()
+	defer b.sourcesMx.Unlock()
+	if _, ok := b.sources[name]; !ok {
+		b.sources[name] = ""
+	}
+}
+
+// logf logs action for the source file src and, if non-empty, the
+// destination file dst when b.Verbose is true. It also records src as
+// the source of dst for the manifest.
+func (b *Build) logf(action, src, dst string) {
+	if dst != "" && b.Manifest {
+		b.sourcesMx.Lock()
+		b.sources[dst] = src
+		b.sourcesMx.Unlock()
+	}
+	if !b.Verbose {
+		return
+	}
+	b.logMx.Lock()
+	defer b.logMx.Unlock()
+	if dst == "" {
+		stderr.Printf("%-12s %s", action, src)
+		return
+	}
+	stderr.Printf("%-12s %s -> %s", action, src, dst)
+}
+
+// markdownExtensions returns the markdown extensions used, which are
+// DefaultMarkdownExtensions if b.MarkdownExtensions is zero.
+func (b *Build) markdownExtensions() int {
+	if b.MarkdownExtensions == 0 {
+		return DefaultMarkdownExtensions
+	}
+	return b.MarkdownExtensions
+}
+
+// minifies returns whether files with the extension ext are minified.
+func (b *Build) minifies(ext string) bool {
+	return !b.NoMinify && !b.NoMinifyExts[ext]
+}
+
+// jobs returns the maximum number of files processed concurrently.
+func (b *Build) jobs() int {
+	if b.Jobs > 0 {
+		return b.Jobs
+	}
+	return runtime.NumCPU()
 }
 
 // MarkdownExts is the extensions considered to be markdown files.
@@ -55,112 +307,300 @@ var MarkdownExts = map[string]bool{
 // TemplateArgs contains the data available to each template.
 // Current is only available in "layout.tmpl" files.
 type TemplateArgs struct {
-	Current Page              // Current markdown file.
-	Dir     []Page            // Markdown files in the same directory.
-	All     map[string][]Page // All markdown pages in the tree.
+	Current *Page              // Current markdown file.
+	Dir     []*Page            // Markdown files in the same directory.
+	All     map[string][]*Page // All markdown pages in the tree.
+	BaseURL string             // Base URL of the site, possibly empty.
+
+	// Recent is the non-draft pages in all directories, in reverse
+	// chronological order.
+	Recent []*Page
+
+	// BuildTime is the time the build started. It is the same for
+	// every file in a build.
+	BuildTime time.Time
+
+	// Paginator is the current page of Dir. It is only available
+	// in "index.html" files.
+	Paginator *Paginator
+
+	// Data is the contents of the files in the "_data" directory,
+	// keyed by file name without extension.
+	Data map[string]interface{}
+
+	// Site is the site-wide values, such as {{ .Site.Title }}.
+	Site Site
+}
+
+// Site represents the site-wide values set in the config file.
+type Site struct {
+	Title       string
+	Description string
+	Author      string
+	BaseURL     string    // Base URL of the site, possibly empty.
+	BuildTime   time.Time // Time the build started.
 }
 
 // Page represents a markdown file.
 type Page struct {
-	Content template.HTML // HTML content generated from markdown.
-	Title   string        // Title from front matter.
-	Time    time.Time     // Timestamp from front matter or file's last modified time.
-	Path    string        // HTTP path at which the page lives.
+	Content     template.HTML // HTML content generated from markdown.
+	Summary     template.HTML // Content before "<!--more-->", or else the first paragraph of Content.
+	Title       string        // Title from front matter.
+	Description string        // Description from front matter.
+	Tags        []string      // Tags from front matter.
+	Time        time.Time     // Timestamp from front matter or file's last modified time.
+	Path        string        // HTTP path at which the page lives.
+	Draft       bool          // Whether the page is a draft.
+	WordCount   int           // Number of words in Content.
+	ReadingTime int           // Minutes to read Content at WordsPerMinute, rounded up.
+
+	// Output is the output file extension from front matter, or ""
+	// for HTML. The page is generated at Path, which ends in the
+	// extension, using the nearest "layout.<Output>.tmpl" file.
+	Output string
+
+	// Layout is the name of the layout file from front matter, used
+	// in place of the nearest "layout.tmpl" file. It is found in the
+	// page's directory or in LayoutsDir. Empty means the nearest
+	// "layout.tmpl" file.
+	Layout string
+
+	Aliases []string // Paths that redirect to Path, from front matter.
+
+	// Params holds the front matter values of keys that are not
+	// otherwise used, such as "image".
+	Params map[string]string
+
+	// HasMermaid indicates whether Content has Mermaid diagrams, so
+	// that layouts can include the Mermaid script only when needed. It
+	// is only set if Build.Mermaid is true.
+	HasMermaid bool
+
+	// HasMath indicates whether Content has math, so that layouts can
+	// include KaTeX only when needed. It is only set if Build.Math is
+	// true.
+	HasMath bool
+
+	// Prev and Next are the chronologically previous (older) and
+	// next (newer) pages in the same directory. They are nil for
+	// the oldest and newest pages respectively.
+	Prev, Next *Page
+}
+
+// moreMarker separates the excerpt of a page from the rest of its
+// content.
+const moreMarker = "<!--more-->"
+
+// Excerpt returns the content before the first "<!--more-->" in
+// Content, or Summary if there is none. Pages made by a build have the
+// marker removed from Content and the content before it in Summary.
+func (p *Page) Excerpt() template.HTML {
+	if summary, _, ok := splitMore(p.Content); ok {
+		return summary
+	}
+	return p.Summary
+}
+
+// splitMore splits content at the first "<!--more-->" into the content
+// before it and the content without it. ok is false if content has no
+// marker. Markers in code blocks are escaped by markdown rendering, so
+// they are not matched.
+func splitMore(content template.HTML) (summary, rest template.HTML, ok bool) {
+	s := string(content)
+	i := strings.Index(s, moreMarker)
+	if i == -1 {
+		return "", content, false
+	}
+	return template.HTML(strings.TrimSpace(s[:i])), template.HTML(s[:i] + strings.TrimLeft(s[i+len(moreMarker):], "\n")), true
+}
+
+// ByTime sorts pages in reverse chronological order. Pages with the
+// same time are sorted by Path, so that the order is the same in every
+// build.
+type ByTime []*Page
+
+func (a ByTime) Len() int      { return len(a) }
+func (a ByTime) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a ByTime) Less(i, j int) bool {
+	if !a[i].Time.Equal(a[j].Time) {
+		return a[i].Time.After(a[j].Time)
+	}
+	return a[i].Path < a[j].Path
 }
 
-// ByTime sorts pages in reverse chronological order.
-type ByTime []Page
+// pageCounts is the number of markdown files included in and skipped
+// from a build.
+type pageCounts struct {
+	Built   int // Pages included, including drafts.
+	Future  int // Included pages whose time is after the build started.
+	Drafts  int // Drafts skipped.
+	Expired int // Expired pages skipped.
+}
 
-func (a ByTime) Len() int           { return len(a) }
-func (a ByTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByTime) Less(i, j int) bool { return !a[i].Time.Before(a[j].Time) }
+func (c pageCounts) String() string {
+	return fmt.Sprintf("built %d pages (%d future-dated), skipped %d drafts and %d expired pages", c.Built, c.Future, c.Drafts, c.Expired)
+}
 
-func (b *Build) makePages(root string) (pages map[string]Page, all map[string][]Page, err error) {
+func (b *Build) makePages(root string) (pages map[string]*Page, all map[string][]*Page, counts pageCounts, err error) {
+	now := time.Now()
 	mx := sync.Mutex{}
-	pages = make(map[string]Page)
-	all = make(map[string][]Page)
+	pages = make(map[string]*Page)
+	mdFuncs := b.Funcs
+	if mdFuncs == nil {
+		mdFuncs = DefaultFuncs()
+	}
+	all = make(map[string][]*Page)
 
 	type result struct {
 		Dir  string
-		Page Page
+		Page *Page
 		Err  error
 	}
 	wg := sync.WaitGroup{}
 	results := make(chan result)
+	sem := make(chan struct{}, b.jobs())
 
+	dirConfigs := make(map[string]DirConfig) // Only used in the Walk function.
 	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if info.IsDir() && p == filepath.Join(root, ArchetypesDir) {
+			return filepath.SkipDir
+		}
 		if info.IsDir() {
 			return nil
 		}
 		if !MarkdownExts[filepath.Ext(p)] {
 			return nil
 		}
+		dc, ok := dirConfigs[filepath.Dir(p)]
+		if !ok {
+			dc, err = loadDirConfig(filepath.Dir(p))
+			if err != nil {
+				return &fileError{filepath.Join(filepath.Dir(p), DirConfigFile), err}
+			}
+			dirConfigs[filepath.Dir(p)] = dc
+		}
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
 			contents, err := ioutil.ReadFile(p)
 			if err != nil {
-				results <- result{Err: err}
+				results <- result{Err: &fileError{p, err}}
 				return
 			}
 
-			page := Page{}
+			page := &Page{}
 
 			innerWg := sync.WaitGroup{}
 			innerWg.Add(1)
+			// The rendering goroutine may send on results, so it must
+			// finish before this goroutine returns and results is closed.
+			defer innerWg.Wait()
 			go func() {
 				defer innerWg.Done()
+				defer b.stats.since(phaseRender, time.Now())
 				buf := bytes.Buffer{}
-				t, err := texttemplate.New("content").Funcs(b.Funcs).Parse(string(contents))
+				t, err := texttemplate.New("content").Funcs(mdFuncs).Parse(string(contents))
 				if err != nil {
-					results <- result{Err: err}
+					results <- result{Err: &fileError{p, err}}
 					return
 				}
 				if err := t.Execute(&buf, nil); err != nil {
-					results <- result{Err: err}
+					results <- result{Err: &fileError{p, err}}
 					return
 				}
-				// NOTE(nishanths): The Renderer returned by HtmlRenderer is not safe for
-				// concurrent use, so create one each time.
-				page.Content = template.HTML(blackfriday.Markdown(
-					trimFrontMatter(buf.Bytes()), blackfriday.HtmlRenderer(blackfridayHTMLFlags, "", ""), blackfridayExtensions,
-				))
+				md := trimFrontMatter(buf.Bytes(), b.FrontMatterSep)
+				var spans []string
+				if b.Math {
+					md, spans = protectMath(md)
+				}
+				page.Content = renderMarkdown(md, b.markdownExtensions(), footnotePrefix(root, p))
+				if b.Math {
+					page.Content, page.HasMath = restoreMath(page.Content, spans)
+				}
+				page.Content = insertTOC(addHeadingIDs(page.Content))
+				if b.HeadingAnchors {
+					page.Content = addHeadingAnchors(page.Content)
+				}
+				if b.Emoji {
+					page.Content = replaceEmoji(page.Content)
+				}
+				if b.Mermaid {
+					page.Content, page.HasMermaid = renderMermaid(page.Content)
+				}
+				if summary, rest, ok := splitMore(page.Content); ok {
+					page.Summary, page.Content = summary, rest
+				} else {
+					page.Summary = summarize(page.Content)
+				}
+				page.WordCount = countWords(page.Content)
+				page.ReadingTime = readingTime(page.WordCount)
+				b.stats.addPage()
 			}()
 
 			fm := FrontMatter{}
-			err = fm.Parse(bytes.NewReader(contents))
+			err = fm.Parse(bytes.NewReader(contents), b.FrontMatterSep)
 			if err != nil && err != ErrNoFrontMatter {
-				results <- result{Err: err}
+				results <- result{Err: &fileError{p, err}}
 				return
 			}
-			if fm.Draft {
+			if fm.Draft && !b.Drafts && b.DraftsTo == "" {
+				b.logf("skip draft", p, "")
+				mx.Lock()
+				counts.Drafts++
+				mx.Unlock()
 				return
 			}
+			if !fm.Expiry.IsZero() && fm.Expiry.Before(time.Now()) && !b.Expired {
+				b.logf("skip expired", p, "")
+				mx.Lock()
+				counts.Expired++
+				mx.Unlock()
+				return
+			}
+			dc.apply(&fm)
+			page.Draft = fm.Draft
+			page.Tags = fm.Tags
+			page.Output = fm.Output
+			page.Layout = fm.Layout
 			if err != ErrNoFrontMatter {
 				page.Title = fm.Title
 				page.Time = fm.Time
+				page.Description = fm.Description
+				page.Aliases = fm.Aliases
+				page.Params = fm.Params
 			} else {
 				page.Title = trimExt(info.Name())
 				page.Time = info.ModTime()
 			}
+			page.Title = fm.TitlePrefix + page.Title
 
 			innerWg.Wait()
 
-			mx.Lock()
-			pages[p] = page
-			mx.Unlock()
-
-			rel, err := filepath.Rel(filepath.Join(".", "src"), p)
+			rel, err := filepath.Rel(root, p)
 			if err != nil {
-				results <- result{Err: err}
+				results <- result{Err: &fileError{p, err}}
 				return
 			}
 			page.Path = "/" + path.Join(filepath.ToSlash(trimExt(rel)))
+			if page.Draft && b.DraftsTo != "" {
+				page.Path = "/" + path.Join(filepath.ToSlash(b.DraftsTo), filepath.ToSlash(trimExt(rel)))
+			}
+			if page.Output != "" {
+				page.Path += "." + page.Output
+			}
+			page.Path = b.basePath() + page.Path
+
+			mx.Lock()
+			pages[p] = page
+			mx.Unlock()
+
 			results <- result{filepath.Dir(rel), page, nil}
 		}()
 
@@ -176,21 +616,58 @@ func (b *Build) makePages(root string) (pages map[string]Page, all map[string][]
 		close(results)
 	}()
 
+	var errs BuildErrors
 	for r := range results {
 		if r.Err != nil {
-			err = r.Err
+			errs = append(errs, r.Err)
+			continue
+		}
+		counts.Built++
+		if r.Page.Time.After(now) {
+			counts.Future++
+		}
+		if r.Page.Draft && b.DraftsTo != "" {
+			continue
 		}
 		all[r.Dir] = append(all[r.Dir], r.Page)
 	}
-	if err != nil {
+	if err = errs.err(); err != nil {
 		return
 	}
 	for k := range all {
 		sort.Sort(ByTime(all[k]))
+		linkPages(all[k])
 	}
 	return
 }
 
+// linkPages sets Prev and Next on pages, which must be sorted by ByTime.
+func linkPages(pages []*Page) {
+	for i, p := range pages {
+		if i > 0 {
+			p.Next = pages[i-1]
+		}
+		if i < len(pages)-1 {
+			p.Prev = pages[i+1]
+		}
+	}
+}
+
+// summarize returns the first paragraph in content, or the empty string
+// if there is none.
+func summarize(content template.HTML) template.HTML {
+	s := string(content)
+	start := strings.Index(s, "<p>")
+	if start == -1 {
+		return ""
+	}
+	end := strings.Index(s[start:], "</p>")
+	if end == -1 {
+		return ""
+	}
+	return template.HTML(s[start : start+end+len("</p>")])
+}
+
 func trimExt(s string) string {
 	return strings.TrimSuffix(s, filepath.Ext(s))
 }
@@ -224,16 +701,56 @@ var minifyFuncs = map[string]struct {
 }
 
 func (b *Build) Run() error {
-	src := "src"
-	build := "build"
+	src := b.srcDir()
+	build := b.outDir()
+	buildTime := time.Now()
+	site := Site{
+		Title:       b.Title,
+		Description: b.Description,
+		Author:      b.Author,
+		BaseURL:     b.BaseURL,
+		BuildTime:   buildTime,
+	}
 
-	filePage, dirPages, err := b.makePages(src)
+	stamps, err := snapshot(src)
+	if err != nil {
+		return err
+	}
+
+	b.stats = nil
+	if b.Profile {
+		b.stats = &buildStats{}
+	}
+	b.overBudget = 0
+	if b.sources == nil || b.only == nil {
+		b.sources = make(map[string]string)
+	}
+
+	start := time.Now()
+	filePage, dirPages, counts, err := b.makePages(src)
+	if err != nil {
+		return err
+	}
+	b.stats.since(phaseMakePages, start)
+
+	drafts := make(map[string]bool)
+	for _, p := range filePage {
+		if p.Draft {
+			drafts[p.Path] = true
+		}
+	}
+	b.draftsMx.Lock()
+	b.drafts = drafts
+	b.draftsMx.Unlock()
+
+	data, err := loadData(filepath.Join(src, DataDir))
 	if err != nil {
 		return err
 	}
 
 	// dirLayout is a map from directory name to the layout template for the
-	// directory.
+	// directory, and from the file of a layout named in front matter to its
+	// template.
 	dirLayout := struct {
 		sync.Mutex
 		m map[string]*template.Template
@@ -245,162 +762,554 @@ func (b *Build) Run() error {
 	mf.AddFunc("text/javascript", js.Minify)
 	mf.AddFunc("image/svg+xml", svg.Minify)
 
-	wg := sync.WaitGroup{}
-	errs := make(chan error)
-	err = filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+	allPages := flatten(dirPages)
+	recent := recentPages(allPages)
+	assets := &assetNames{enabled: b.Fingerprint, dir: build}
+	funcs := b.layoutFuncs(assets, allPages, dirPages)
+
+	partials, err := loadPartials(filepath.Join(src, PartialsDir), funcs)
+	if err != nil {
+		return err
+	}
+	pfiles, err := partialFiles(filepath.Join(src, PartialsDir))
+	if err != nil {
+		return err
+	}
+
+	buildFile := func(p string, info os.FileInfo) error {
+		rem, err := filepath.Rel(src, p)
 		if err != nil {
 			return err
 		}
+		_, minifiable := minifyFuncs[filepath.Ext(p)]
 
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			_, minifiable := minifyFuncs[filepath.Ext(p)]
-
-			switch {
-			case info.IsDir() || info.Name() == "layout.tmpl":
-				return
+		switch {
+		case minifiable:
+			in, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			buf := bytes.Buffer{}
+			action := "copy"
+			if b.minifies(filepath.Ext(p)) {
+				action = "minify"
+				start := time.Now()
+				err = minifyFuncs[filepath.Ext(p)].fn(mf, &buf, in, nil)
+				b.stats.since(phaseMinify, start)
+			} else {
+				_, err = buf.ReadFrom(in)
+			}
+			if err != nil {
+				return err
+			}
+			if b.Fingerprint {
+				fp := fingerprintName(rem, buf.Bytes())
+				assets.add("/"+filepath.ToSlash(rem), "/"+filepath.ToSlash(fp))
+				rem = fp
+			}
+			b.logf(action, p, filepath.Join(build, rem))
+			return b.write(filepath.Join(build, rem), buf.Bytes())
 
-			case minifiable:
-				in, err := os.Open(p)
-				if err != nil {
-					errs <- err
-					return
-				}
-				defer in.Close()
-				rem, err := filepath.Rel(src, p)
-				if err != nil {
-					errs <- err
-					return
-				}
-				out, err := createFile(filepath.Join(build, rem))
-				if err != nil {
-					errs <- err
-					return
-				}
-				defer out.Close()
-				if err := minifyFuncs[filepath.Ext(p)].fn(mf, out, in, nil); err != nil {
-					errs <- err
-					return
+		case scssExts[filepath.Ext(p)]:
+			if strings.HasPrefix(info.Name(), "_") {
+				// Partial, compiled into the files that import it.
+				return nil
+			}
+			css, err := compileSCSS(p)
+			if err != nil {
+				return err
+			}
+			rem = trimExt(rem) + ".css"
+			if b.minifies(".css") {
+				buf := bytes.Buffer{}
+				start := time.Now()
+				if err := minifyFuncs[".css"].fn(mf, &buf, bytes.NewReader(css), nil); err != nil {
+					return fmt.Errorf("%s: %s", p, err)
 				}
-				out.Sync()
+				b.stats.since(phaseMinify, start)
+				css = buf.Bytes()
+			}
+			if b.Fingerprint {
+				fp := fingerprintName(rem, css)
+				assets.add("/"+filepath.ToSlash(rem), "/"+filepath.ToSlash(fp))
+				rem = fp
+			}
+			b.logf("compile", p, filepath.Join(build, rem))
+			return b.write(filepath.Join(build, rem), css)
 
-			case MarkdownExts[filepath.Ext(p)]:
-				// Get layout template.
-				dirLayout.Lock()
-				ltmpl, ok := dirLayout.m[filepath.Dir(p)]
-				dirLayout.Unlock()
-				if !ok {
-					var err error
-					ltmpl, err = template.ParseFiles(filepath.Join(filepath.Dir(p), "layout.tmpl"))
+		case MarkdownExts[filepath.Ext(p)]:
+			if filePage[p] == nil {
+				// Excluded draft or expired page.
+				return nil
+			}
+			args := TemplateArgs{
+				Current:   filePage[p],
+				Dir:       dirPages[filepath.Dir(p)],
+				All:       dirPages,
+				Recent:    recent,
+				BaseURL:   b.BaseURL,
+				BuildTime: buildTime,
+				Data:      data,
+				Site:      site,
+			}
+			name := b.outputName(build, rem, filePage[p])
+			if filePage[p].Output != "" {
+				b.logf("render", p, name)
+				return b.executeOutput(src, p, funcs, name, args)
+			}
+
+			// Get layout template: the page's named layout, or else the
+			// directory's.
+			key := filepath.Dir(p)
+			if name := filePage[p].Layout; name != "" {
+				if key, err = namedLayoutFile(src, filepath.Dir(p), name); err != nil {
+					return err
+				}
+			}
+			dirLayout.Lock()
+			ltmpl, ok := dirLayout.m[key]
+			dirLayout.Unlock()
+			if !ok {
+				files := []string{key}
+				if key == filepath.Dir(p) {
+					files, err = layoutFiles(src, filepath.Dir(p), layoutName(""))
 					if err != nil {
-						if os.IsNotExist(err) {
-							err = fmt.Errorf("missing layout.tmpl file in %q", p)
-						}
-						errs <- err
-						return
+						return err
 					}
-					dirLayout.Lock()
-					dirLayout.m[filepath.Dir(p)] = ltmpl
-					dirLayout.Unlock()
-				}
-				// Create index.html in a directory with same name in build.
-				rem, err := filepath.Rel(src, p)
-				if err != nil {
-					errs <- err
-					return
-				}
-				f, err := createFile(filepath.Join(build, trimExt(rem), "index.html"))
-				if err != nil {
-					errs <- err
-					return
-				}
-				defer f.Close()
-
-				w := mf.Writer("text/html", f)
-				defer w.Close()
-				if err := ltmpl.Execute(w, TemplateArgs{
-					Current: filePage[p],
-					Dir:     dirPages[filepath.Dir(p)],
-					All:     dirPages,
-				}); err != nil {
-					// TODO(nishanths): Fix this check. Appears to be issue
-					// with minify package.
-					if err != io.ErrClosedPipe {
-						errs <- err
-						return
+					if len(files) == 0 {
+						return fmt.Errorf("missing layout.tmpl file in %q or its parent directories", filepath.Dir(p))
 					}
 				}
-				f.Sync()
-
-			case filepath.Ext(p) == ".html":
-				// Create corresponding .html file in build and
-				// execute as template.
-				tmpl, err := template.ParseFiles(p)
-				if err != nil {
-					errs <- err
-					return
-				}
-				rem, err := filepath.Rel(src, p)
-				if err != nil {
-					errs <- err
-					return
-				}
-				f, err := createFile(filepath.Join(build, rem))
+				ltmpl, err = b.Templates.parse(partials, pfiles, funcs, files...)
 				if err != nil {
-					errs <- err
-					return
+					return err
 				}
-				defer f.Close()
+				dirLayout.Lock()
+				dirLayout.m[key] = ltmpl
+				dirLayout.Unlock()
+			}
+			b.logf("render", p, name)
+			return b.executeHTML(mf, ltmpl, name, args)
 
-				rel, err := filepath.Rel(filepath.Join(".", "src"), p)
-				if err != nil {
-					errs <- err
-					return
-				}
+		case filepath.Ext(p) == ".html":
+			// Create corresponding .html file in build and
+			// execute as template.
+			tmpl, err := b.Templates.parse(partials, pfiles, funcs, p)
+			if err != nil {
+				return err
+			}
 
-				w := mf.Writer("text/html", f)
-				defer w.Close()
-				if err := tmpl.Execute(w, TemplateArgs{
-					Dir: dirPages[rel],
-					All: dirPages,
-				}); err != nil {
-					// TODO(nishanths): Fix this check. Appears to be issue
-					// with minify package.
-					if err != io.ErrClosedPipe {
-						errs <- err
-						return
-					}
-				}
-				f.Sync()
+			args := TemplateArgs{
+				Dir:       dirPages[filepath.Dir(rem)],
+				All:       dirPages,
+				Recent:    recent,
+				BaseURL:   b.BaseURL,
+				BuildTime: buildTime,
+				Data:      data,
+				Site:      site,
+			}
+			if info.Name() != "index.html" {
+				b.logf("render", p, filepath.Join(build, rem))
+				return b.executeHTML(mf, tmpl, filepath.Join(build, rem), args)
+			}
 
-			default:
-				// All other files - simply copy.
-				rem, err := filepath.Rel(src, p)
-				if err != nil {
-					errs <- err
-					return
+			// Directory index; paginate the pages in the directory.
+			dir := filepath.Dir(rem)
+			for _, pg := range paginate(args.Dir, b.PageSize, b.basePath()+"/"+filepath.ToSlash(dir)) {
+				args.Paginator = pg
+				name := filepath.Join(build, dir, "index.html")
+				if pg.PageNum > 1 {
+					name = filepath.Join(build, dir, "page", strconv.Itoa(pg.PageNum), "index.html")
+				}
+				b.logf("render", p, name)
+				if err := b.executeHTML(mf, tmpl, name, args); err != nil {
+					return err
 				}
-				errs <- copyFile(filepath.Join(build, rem), p)
 			}
-		}()
-		return nil
-	})
+			return nil
 
+		case b.OptimizeImages && imageExts[filepath.Ext(p)] != "":
+			quality := b.ImageQuality
+			if quality == 0 {
+				quality = DefaultImageQuality
+			}
+			b.logf("optimize", p, filepath.Join(build, rem))
+			if err := optimizeImage(filepath.Join(build, rem), p, quality); err != nil {
+				return err
+			}
+			b.generated(filepath.Join(build, rem))
+			return nil
+
+		default:
+			// All other files - simply copy.
+			b.logf("copy", p, filepath.Join(build, rem))
+			return b.copy(filepath.Join(build, rem), p, info.Size())
+		}
+	}
+
+	// Minifiable assets and SCSS files are built first, so that
+	// templates can refer to their fingerprinted names and integrity
+	// values.
+	isAsset := func(p string) bool {
+		_, ok := minifyFuncs[filepath.Ext(p)]
+		return ok || scssExts[filepath.Ext(p)]
+	}
+	selected := func(p string) bool { return b.only == nil || b.only[p] }
+	if err := b.walk(src, func(p string) bool { return isAsset(p) && selected(p) }, buildFile); err != nil {
+		return err
+	}
+
+	if b.page != "" {
+		return b.renderPage(src, build, filePage, buildFile)
+	}
+
+	if b.Robots {
+		if err := writeRobots(src, build, b.BaseURL, b.Sitemap); err != nil {
+			return err
+		}
+		b.generated(filepath.Join(build, RobotsFile))
+	}
+
+	aliases, err := writeAliases(src, build, b.BaseURL, filePage)
 	if err != nil {
 		return err
 	}
+	for _, name := range aliases {
+		b.generated(name)
+	}
+
+	f := Feed{Title: b.Title, BaseURL: b.BaseURL, Author: b.Author, FullContent: b.FeedFullContent, BuildTime: buildTime}
+	if b.RSS {
+		if err := writeFeed(filepath.Join(build, FeedFile), f.WriteRSS, filePage); err != nil {
+			return err
+		}
+		b.generated(filepath.Join(build, FeedFile))
+	}
+	if b.JSONFeed {
+		if err := writeFeed(filepath.Join(build, JSONFeedFile), f.WriteJSON, filePage); err != nil {
+			return err
+		}
+		b.generated(filepath.Join(build, JSONFeedFile))
+	}
+	if b.Atom {
+		if err := writeFeed(filepath.Join(build, AtomFile), f.WriteAtom, filePage); err != nil {
+			return err
+		}
+		b.generated(filepath.Join(build, AtomFile))
+	}
+
+	if b.Sitemap {
+		if err := writeSitemap(filepath.Join(build, SitemapFile), b.BaseURL, filePage); err != nil {
+			return err
+		}
+		b.generated(filepath.Join(build, SitemapFile))
+	}
+
+	if b.SearchIndex {
+		if err := writeSearchIndex(filepath.Join(build, SearchIndexFile), filePage); err != nil {
+			return err
+		}
+		b.generated(filepath.Join(build, SearchIndexFile))
+	}
+
+	if err := b.walk(src, func(p string) bool { return !isAsset(p) && selected(p) }, buildFile); err != nil {
+		return err
+	}
+
+	if b.AutoIndex {
+		tmpl, err := listTemplate(src, partials)
+		if err != nil {
+			return err
+		}
+		args := TemplateArgs{All: dirPages, Recent: recent, BaseURL: b.BaseURL, BuildTime: buildTime, Data: data, Site: site}
+		if err := b.writeAutoIndexes(mf, src, build, tmpl, args, dirPages); err != nil {
+			return err
+		}
+	}
+
+	if b.CheckLinks {
+		if err := checkLinks(build, b.basePath()); err != nil {
+			return err
+		}
+	}
+
+	if b.Compress {
+		isText := func(p string) bool { return compressExts[filepath.Ext(p)] }
+		err := b.walk(build, isText, func(p string, info os.FileInfo) error {
+			b.logf("compress", p, p+".gz")
+			return compressFile(p)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if b.Manifest {
+		b.sourcesMx.Lock()
+		err := writeManifest(build, b.sources)
+		b.sourcesMx.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	b.stamps = stamps
+	b.counts = counts
+	if b.overBudget > 0 {
+		stderr.Printf("warning: %d HTML files over the size budget of %d bytes", b.overBudget, b.SizeBudget)
+	}
+	if b.Profile {
+		b.stats.print(stderr.Writer(), time.Since(buildTime))
+	}
+	return nil
+}
+
+// walk calls fn concurrently for each file in root for which match
+// returns true. Directories, layout files, directory config files, and
+// the partials and defaults directories are skipped. At most
+// b.jobs() calls run at once. The errors from all calls, annotated with
+// the file path, are returned as BuildErrors.
+func (b *Build) walk(root string, match func(p string) bool, fn func(p string, info os.FileInfo) error) error {
+	wg := sync.WaitGroup{}
+	errs := make(chan error)
+	sem := make(chan struct{}, b.jobs())
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (p == filepath.Join(root, PartialsDir) || p == filepath.Join(root, DefaultsDir) || p == filepath.Join(root, DataDir) || p == filepath.Join(root, ArchetypesDir) || p == filepath.Join(root, LayoutsDir)) {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || filepath.Ext(info.Name()) == ".tmpl" || info.Name() == DirConfigFile || !match(p) {
+			return nil
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := fn(p, info); err != nil {
+				errs <- &fileError{p, err}
+			}
+		}()
+		return nil
+	})
 
 	go func() {
 		wg.Wait()
 		close(errs)
 	}()
 
-	for err := range errs {
-		if err != nil {
+	var all BuildErrors
+	for e := range errs {
+		all = append(all, e)
+	}
+	if err != nil {
+		all = append(all, err)
+	}
+	return all.err()
+}
+
+// outputName returns the name of the file in build generated from the
+// markdown file rem, relative to the source directory, for the page pg.
+func (b *Build) outputName(build, rem string, pg *Page) string {
+	base := filepath.Join(build, trimExt(rem))
+	if pg.Draft && b.DraftsTo != "" {
+		base = filepath.Join(build, b.DraftsTo, trimExt(rem))
+	}
+	if pg.Output != "" {
+		return base + "." + pg.Output
+	}
+	// index.html in a directory with same name in build.
+	return filepath.Join(base, "index.html")
+}
+
+// RenderPage renders the markdown file p in Src alone, with its layout,
+// to w, without writing to Out. The assets that layouts refer to, such
+// as with "fingerprint" and "sri", are built into a temporary directory.
+// Files made from all pages, such as feeds and the search index, are
+// skipped.
+func (b *Build) RenderPage(p string, w io.Writer) error {
+	p = filepath.Clean(p)
+	if !MarkdownExts[filepath.Ext(p)] {
+		return fmt.Errorf("%s: not a markdown file", p)
+	}
+	tmp, err := ioutil.TempDir("", "batsman")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	out := b.Out
+	b.Out, b.page, b.pageOut = tmp, p, w
+	defer func() { b.Out, b.page, b.pageOut = out, "", nil }()
+	return b.Run()
+}
+
+// renderPage renders b.page with buildFile, after the assets are built,
+// and copies the generated file to b.pageOut.
+func (b *Build) renderPage(src, build string, filePage map[string]*Page, buildFile func(p string, info os.FileInfo) error) error {
+	pg := filePage[b.page]
+	if pg == nil {
+		return fmt.Errorf("%s: not a page in %q, or an excluded draft or expired page", b.page, src)
+	}
+	info, err := os.Stat(b.page)
+	if err != nil {
+		return err
+	}
+	if err := buildFile(b.page, info); err != nil {
+		return &fileError{b.page, err}
+	}
+
+	rem, err := filepath.Rel(src, b.page)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(b.outputName(build, rem, pg))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(b.pageOut, f)
+	return err
+}
+
+// executeHTML executes tmpl with args and writes the output, minified
+// if b.minifies(".html"), to the named file.
+//
+// The template is executed into a buffer rather than a minify.M writer:
+// the writer's lexer treats an empty write, which templates produce for
+// empty values, as the end of input and truncates the output.
+func (b *Build) executeHTML(mf *minify.M, tmpl *template.Template, name string, args TemplateArgs) error {
+	buf := bytes.Buffer{}
+	start := time.Now()
+	if err := tmpl.Execute(&buf, args); err != nil {
+		return err
+	}
+	b.stats.since(phaseExecute, start)
+
+	if b.minifies(".html") {
+		out := bytes.Buffer{}
+		start := time.Now()
+		if err := mf.Minify("text/html", &out, &buf); err != nil {
 			return err
 		}
+		b.stats.since(phaseMinify, start)
+		buf = out
+	}
+	b.checkSize(name, buf.Len())
+	return b.write(name, buf.Bytes())
+}
+
+// checkSize warns if size, the size of the named HTML file, is over
+// b.SizeBudget, and counts the file for the summary at the end of the
+// run.
+func (b *Build) checkSize(name string, size int) {
+	if b.SizeBudget <= 0 || size <= b.SizeBudget {
+		return
+	}
+	b.overBudgetMx.Lock()
+	defer b.overBudgetMx.Unlock()
+	b.overBudget++
+	stderr.Printf("warning: %s is %d bytes, over the size budget of %d bytes", name, size, b.SizeBudget)
+}
+
+// write writes data to the named file if it changed, recording the
+// time taken and the size in b.stats.
+func (b *Build) write(name string, data []byte) error {
+	defer b.stats.since(phaseWrite, time.Now())
+	b.stats.addBytes(len(data))
+	if _, err := writeIfChanged(name, data); err != nil {
+		return err
 	}
+	b.generated(name)
+	return nil
+}
 
+// copy copies the file src, of size bytes, to dst if their contents
+// differ, recording the time taken and the size in b.stats.
+func (b *Build) copy(dst, src string, size int64) error {
+	defer b.stats.since(phaseWrite, time.Now())
+	b.stats.addBytes(int(size))
+	if _, err := copyIfChanged(dst, src); err != nil {
+		return err
+	}
+	b.generated(dst)
 	return nil
 }
+
+// executeOutput executes the nearest layout for the output extension of
+// the markdown file p, such as "layout.txt.tmpl", with args and writes
+// the output verbatim to the named file. The output is not HTML, so the
+// layout is parsed as a text/template and partials are not available.
+func (b *Build) executeOutput(root, p string, funcs template.FuncMap, name string, args TemplateArgs) error {
+	lname := layoutName(args.Current.Output)
+	files, err := layoutFiles(root, filepath.Dir(p), lname)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("missing %s file in %q or its parent directories", lname, filepath.Dir(p))
+	}
+	t, err := texttemplate.New(lname).Funcs(texttemplate.FuncMap(funcs)).ParseFiles(files...)
+	if err != nil {
+		return err
+	}
+	buf := bytes.Buffer{}
+	start := time.Now()
+	if err := t.Execute(&buf, args); err != nil {
+		return err
+	}
+	b.stats.since(phaseExecute, start)
+	return b.write(name, buf.Bytes())
+}
+
+// layoutFuncs returns the functions available to layout and .html
+// templates. assets resolves fingerprinted names, all is the pages
+// searched by related, and dirs is the pages listed by pagesIn.
+func (b *Build) layoutFuncs(assets *assetNames, all []*Page, dirs map[string][]*Page) template.FuncMap {
+	return template.FuncMap{
+		"fingerprint":  assets.fingerprint,
+		"sri":          assets.sri,
+		"formatTime":   formatTime,
+		"now":          time.Now,
+		"Markdown":     markdown,
+		"byTag":        byTag,
+		"first":        first,
+		"groupByYear":  groupByYear,
+		"groupByMonth": groupByMonth,
+		"truncate":     truncate,
+		"related": func(cur *Page, n int) []*Page {
+			return relatedPages(cur, all, n)
+		},
+		"pagesIn": func(dir string) []*Page {
+			return pagesIn(dirs, dir)
+		},
+		"where": where,
+		"jsonLD": func(p *Page, baseURL string) (template.HTML, error) {
+			return jsonLD(p, baseURL, b.Author, b.Title)
+		},
+		"canonical": canonical,
+		"openGraph": openGraph,
+		"absURL": func(p string) string {
+			return joinURL(b.BaseURL, p)
+		},
+		"relURL": func(p string) string {
+			return relURL(b.basePath(), p)
+		},
+		"mermaidScript": func() template.HTML {
+			return mermaidScript
+		},
+		"mathScript": func() template.HTML {
+			return mathScript
+		},
+		"readFile": func(name string) (string, error) {
+			return readSrcFile(b.srcDir(), name)
+		},
+		"highlightFile": func(name string) (template.HTML, error) {
+			return highlightFile(b.srcDir(), name)
+		},
+	}
+}
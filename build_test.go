@@ -0,0 +1,947 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	texttemplate "text/template"
+	"time"
+)
+
+// writeTree creates the files in the map, keyed by slash-separated path
+// relative to root.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		if err := createFileWithData(
+			filepath.Join(root, filepath.FromSlash(name)),
+			strings.NewReader(data),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the contents of the file at the slash-separated path,
+// failing the test if it cannot be read.
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.FromSlash(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestBuildJobs(t *testing.T) {
+	const n = 50
+	const jobs = 2
+
+	files := map[string]string{
+		"src/layout.tmpl": `{{ .Current.Content }}`,
+	}
+	for i := 0; i < n; i++ {
+		files[fmt.Sprintf("src/p%d.md", i)] = fmt.Sprintf("{{ Track }}page %d", i)
+	}
+	dir := t.TempDir()
+	writeTree(t, dir, files)
+	t.Chdir(dir)
+
+	mx := sync.Mutex{}
+	active, max := 0, 0
+	b := &Build{
+		Funcs: texttemplate.FuncMap{
+			"Track": func() string {
+				mx.Lock()
+				active++
+				if active > max {
+					max = active
+				}
+				mx.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mx.Lock()
+				active--
+				mx.Unlock()
+				return ""
+			},
+		},
+		Jobs: jobs,
+	}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if max > jobs {
+		t.Fatalf("concurrent jobs: got %d, expected at most %d", max, jobs)
+	}
+	for i := 0; i < n; i++ {
+		got := readFile(t, fmt.Sprintf("build/p%d/index.html", i))
+		if expected := fmt.Sprintf("page %d", i); !strings.Contains(got, expected) {
+			t.Fatalf("build/p%d/index.html: got %q, expected to contain %q", i, got, expected)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("build", "layout.tmpl")); !os.IsNotExist(err) {
+		t.Fatalf("layout.tmpl: expected not to be copied, got err %v", err)
+	}
+}
+
+func TestBuildDrafts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/post.md": "post",
+		"src/draft.md": `+++
+title = "draft"
+draft = true
++++
+draft`,
+	})
+	src := filepath.Join(dir, "src")
+
+	testcases := []struct {
+		drafts   bool
+		expected map[string]bool // Page titles to whether they are drafts.
+	}{
+		{false, map[string]bool{"post": false}},
+		{true, map[string]bool{"post": false, "draft": true}},
+	}
+
+	for _, tc := range testcases {
+		b := &Build{Drafts: tc.drafts}
+		pages, all, _, err := b.makePages(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pages) != len(tc.expected) || len(all["."]) != len(tc.expected) {
+			t.Fatalf("Drafts=%t: got %d pages and %d in directory, expected %d",
+				tc.drafts, len(pages), len(all["."]), len(tc.expected))
+		}
+		for _, p := range all["."] {
+			draft, ok := tc.expected[p.Title]
+			if !ok {
+				t.Fatalf("Drafts=%t: unexpected page %q", tc.drafts, p.Title)
+			}
+			if p.Draft != draft {
+				t.Fatalf("Drafts=%t: page %q: got Draft %t, expected %t", tc.drafts, p.Title, p.Draft, draft)
+			}
+		}
+	}
+}
+
+func TestBuildPrevNext(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `prev:{{ with .Current.Prev }}{{ .Title }}{{ end }} next:{{ with .Current.Next }}{{ .Title }}{{ end }}`,
+		"src/a.md":        "+++\ntitle = \"a\"\ntime = \"2016-01-01\"\n+++\n",
+		"src/b.md":        "+++\ntitle = \"b\"\ntime = \"2016-01-02\"\n+++\n",
+		"src/c.md":        "+++\ntitle = \"c\"\ntime = \"2016-01-03\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	_, all, _, err := (&Build{}).makePages("src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	title := func(p *Page) string {
+		if p == nil {
+			return ""
+		}
+		return p.Title
+	}
+	expected := []struct {
+		title, prev, next string
+	}{
+		{"c", "b", ""},
+		{"b", "a", "c"},
+		{"a", "", "b"},
+	}
+	pages := all["."]
+	if len(pages) != len(expected) {
+		t.Fatalf("got %d pages, expected %d", len(pages), len(expected))
+	}
+	for i, e := range expected {
+		p := pages[i]
+		if p.Title != e.title || title(p.Prev) != e.prev || title(p.Next) != e.next {
+			t.Fatalf("page %d: got %q (prev %q, next %q), expected %q (prev %q, next %q)",
+				i, p.Title, title(p.Prev), title(p.Next), e.title, e.prev, e.next)
+		}
+	}
+
+	if err := (&Build{}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range expected {
+		got := readFile(t, "build/"+e.title+"/index.html")
+		if s := "prev:" + e.prev + " next:" + e.next; got != s {
+			t.Fatalf("build/%s/index.html: got %q, expected %q", e.title, got, s)
+		}
+	}
+}
+
+func TestBuildPaginate(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/blog/layout.tmpl": `{{ .Current.Title }}`,
+		"src/blog/index.html":  `{{ range .Paginator.Pages }}{{ .Title }},{{ end }}{{ .Paginator.PrevURL }}|{{ .Paginator.NextURL }}`,
+		"src/blog/a.md":        "+++\ntitle = \"a\"\ntime = \"2016-01-01\"\n+++\n",
+		"src/blog/b.md":        "+++\ntitle = \"b\"\ntime = \"2016-01-02\"\n+++\n",
+		"src/blog/c.md":        "+++\ntitle = \"c\"\ntime = \"2016-01-03\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{PageSize: 2}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name, expected string
+	}{
+		{"build/blog/index.html", "c,b,|/blog/page/2/"},
+		{"build/blog/page/2/index.html", "a,/blog/|"},
+	}
+	for _, tc := range testcases {
+		if got := readFile(t, tc.name); got != tc.expected {
+			t.Fatalf("%s: got %q, expected %q", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildMinify(t *testing.T) {
+	const css = "a {\n\tcolor: red;\n}\n"
+	const page = "<p>  hello  </p>\n"
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/style.css": css,
+		"src/page.html": page,
+	})
+	t.Chdir(dir)
+
+	testcases := []struct {
+		noMinify  bool
+		css, page string
+	}{
+		{false, "a{color:red}", "<p>hello"},
+		{true, css, page},
+	}
+
+	for _, tc := range testcases {
+		if err := (&Build{NoMinify: tc.noMinify}).Run(); err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, "build/style.css"); got != tc.css {
+			t.Fatalf("NoMinify=%t: build/style.css: got %q, expected %q", tc.noMinify, got, tc.css)
+		}
+		if got := readFile(t, "build/page.html"); got != tc.page {
+			t.Fatalf("NoMinify=%t: build/page.html: got %q, expected %q", tc.noMinify, got, tc.page)
+		}
+	}
+}
+
+func TestBuildNoMinify(t *testing.T) {
+	const css = "a {\n\tcolor: red;\n}\n"
+	const svg = "<svg xmlns=\"http://www.w3.org/2000/svg\">\n  <path d=\"M 0 0 L 10 10\"/>\n</svg>\n"
+	const page = "<p>  hello  </p>\n"
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/style.css": css,
+		"src/icon.svg":  svg,
+		"src/page.html": page,
+	})
+	t.Chdir(dir)
+
+	b := &Build{NoMinifyExts: map[string]bool{".svg": true, ".html": true, ".js": false}}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"build/style.css": "a{color:red}",
+		"build/icon.svg":  svg,
+		"build/page.html": page,
+	} {
+		if got := readFile(t, name); got != expected {
+			t.Fatalf("%s: got %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+func TestBuildTime(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/layout.tmpl": `{{ .BuildTime.UnixNano }}`,
+		"src/index.html":  `{{ .BuildTime.UnixNano }}`,
+	}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("src/p%d.md", i)] = "page"
+	}
+	writeTree(t, dir, files)
+	t.Chdir(dir)
+
+	before := time.Now()
+	if err := (&Build{Jobs: 4}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := readFile(t, "build/index.html")
+	if expected == "" || expected < fmt.Sprint(before.UnixNano()) {
+		t.Fatalf("build/index.html: got %q, expected a time after %d", expected, before.UnixNano())
+	}
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("build/p%d/index.html", i)
+		if got := readFile(t, name); got != expected {
+			t.Fatalf("%s: got %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+func TestBuildSite(t *testing.T) {
+	dir := t.TempDir()
+	site := `{{ .Site.Title }}|{{ .Site.Description }}|{{ .Site.Author }}|{{ .Site.BaseURL }}|{{ .Site.BuildTime.Equal .BuildTime }}`
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": site,
+		"src/index.html":  site,
+		"src/post.md":     "post",
+	})
+	t.Chdir(dir)
+
+	b := &Build{
+		Title:       "My site",
+		Description: "Notes on Go",
+		Author:      "Jane Doe",
+		BaseURL:     "https://example.com",
+	}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "My site|Notes on Go|Jane Doe|https://example.com|true"
+	for _, name := range []string{"build/index.html", "build/post/index.html"} {
+		if got := readFile(t, name); got != expected {
+			t.Fatalf("%s: got %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+func TestBuildPartials(t *testing.T) {
+	testcases := []struct {
+		layout   string
+		expected string // Expected build/post/index.html, or "" for an error.
+	}{
+		{`{{ template "header" . }}{{ .Current.Content }}`, "<header>post</header><p>body</p>\n"},
+		{`{{ template "missing" . }}{{ .Current.Content }}`, ""},
+	}
+
+	for _, tc := range testcases {
+		dir := t.TempDir()
+		writeTree(t, dir, map[string]string{
+			"src/_partials/header.tmpl": `{{ define "header" }}<header>{{ .Current.Title }}</header>{{ end }}`,
+			"src/layout.tmpl":           tc.layout,
+			"src/post.md":               "body",
+		})
+		t.Chdir(dir)
+
+		err := (&Build{NoMinify: true}).Run()
+		if tc.expected == "" {
+			if err == nil {
+				t.Fatalf("%s: expected error", tc.layout)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, "build/post/index.html"); got != tc.expected {
+			t.Fatalf("%s: got %q, expected %q", tc.layout, got, tc.expected)
+		}
+		if _, err := os.Stat(filepath.Join("build", PartialsDir)); !os.IsNotExist(err) {
+			t.Fatalf("%s: expected not to be copied, got err %v", PartialsDir, err)
+		}
+	}
+}
+
+func TestMakePagesCounts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/a.md":       "a",
+		"src/b/b.md":     "+++\ntime = \"2016-01-01\"\n+++\n",
+		"src/draft.md":   "+++\ndraft = true\n+++\n",
+		"src/b/draft.md": "+++\ndraft = true\n+++\n",
+		"src/future.md":  "+++\ntime = \"2999-01-01\"\n+++\n",
+		"src/expired.md": "+++\nexpiry = \"2000-01-01\"\n+++\n",
+	})
+	src := filepath.Join(dir, "src")
+
+	testcases := []struct {
+		b        *Build
+		expected pageCounts
+	}{
+		{&Build{}, pageCounts{Built: 3, Future: 1, Drafts: 2, Expired: 1}},
+		{&Build{Drafts: true}, pageCounts{Built: 5, Future: 1, Expired: 1}},
+		{&Build{DraftsTo: "drafts", Expired: true}, pageCounts{Built: 6, Future: 1}},
+	}
+
+	for _, tc := range testcases {
+		_, _, got, err := tc.b.makePages(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.expected {
+			t.Fatalf("makePages: got %+v, expected %+v", got, tc.expected)
+		}
+	}
+
+	expected := "built 3 pages (1 future-dated), skipped 2 drafts and 1 expired pages"
+	if got := testcases[0].expected.String(); got != expected {
+		t.Fatalf("String: got %q, expected %q", got, expected)
+	}
+}
+
+func TestBuildCountsNotPrinted(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `{{ .Current.Content }}`,
+		"src/a.md":        "a",
+		"src/draft.md":    "+++\ndraft = true\n+++\n",
+	})
+	t.Chdir(dir)
+
+	var buf bytes.Buffer
+	stderr.SetOutput(&buf)
+	info.SetOutput(&buf)
+	defer stderr.SetOutput(os.Stderr)
+	defer info.SetOutput(os.Stderr)
+
+	b := &Build{}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Run: got output %q, expected none; the CLI prints the counts", buf.String())
+	}
+	if expected := (pageCounts{Built: 1, Drafts: 1}); b.counts != expected {
+		t.Fatalf("counts: got %+v, expected %+v", b.counts, expected)
+	}
+}
+
+func TestBuildExpired(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/post.md":    "post",
+		"src/expired.md": "+++\ntitle = \"expired\"\nexpiry = \"2000-01-01\"\n+++\n",
+		"src/future.md":  "+++\ntitle = \"future\"\nexpiry = \"2999-01-01\"\n+++\n",
+	})
+	src := filepath.Join(dir, "src")
+
+	testcases := []struct {
+		expired  bool
+		expected []string // Page titles, in any order.
+	}{
+		{false, []string{"post", "future"}},
+		{true, []string{"post", "future", "expired"}},
+	}
+
+	for _, tc := range testcases {
+		_, all, _, err := (&Build{Expired: tc.expired}).makePages(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]bool)
+		for _, p := range all["."] {
+			got[p.Title] = true
+		}
+		if len(got) != len(tc.expected) {
+			t.Fatalf("Expired=%t: got %v, expected %v", tc.expired, got, tc.expected)
+		}
+		for _, title := range tc.expected {
+			if !got[title] {
+				t.Fatalf("Expired=%t: got %v, expected %v", tc.expired, got, tc.expected)
+			}
+		}
+	}
+}
+
+func TestBuildVerbose(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `{{ .Current.Content }}`,
+		"src/post.md":     "post",
+		"src/draft.md":    "+++\ndraft = true\n+++\n",
+		"src/style.css":   "a { color: red; }",
+		"src/robots.txt":  "",
+	})
+	t.Chdir(dir)
+
+	buf := bytes.Buffer{}
+	stderr.SetOutput(&buf)
+	defer stderr.SetOutput(os.Stderr)
+
+	if err := (&Build{Verbose: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	for _, expected := range []string{
+		"skip draft   " + filepath.Join("src", "draft.md") + "\n",
+		"render       " + filepath.Join("src", "post.md") + " -> " + filepath.Join("build", "post", "index.html") + "\n",
+		"minify       " + filepath.Join("src", "style.css") + " -> " + filepath.Join("build", "style.css") + "\n",
+		"copy         " + filepath.Join("src", "robots.txt") + " -> " + filepath.Join("build", "robots.txt") + "\n",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("got %q, expected to contain %q", got, expected)
+		}
+	}
+	if n := strings.Count(got, "\n"); n != 4 {
+		t.Fatalf("got %d lines, expected 4:\n%s", n, got)
+	}
+}
+
+func TestBuildSizeBudget(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `{{ .Current.Content }}`,
+		"src/small.md":    "small",
+		"src/large.md":    strings.Repeat("large ", 50),
+		"src/index.html":  strings.Repeat("index ", 50),
+	})
+	t.Chdir(dir)
+
+	buf := bytes.Buffer{}
+	stderr.SetOutput(&buf)
+	defer stderr.SetOutput(os.Stderr)
+
+	if err := (&Build{NoMinify: true, SizeBudget: 100}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	for _, expected := range []string{
+		"warning: " + filepath.Join("build", "large", "index.html") + " is ",
+		"warning: " + filepath.Join("build", "index.html") + " is 300 bytes, over the size budget of 100 bytes\n",
+		"warning: 2 HTML files over the size budget of 100 bytes\n",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Fatalf("got %q, expected to contain %q", got, expected)
+		}
+	}
+	if strings.Contains(got, filepath.Join("build", "small", "index.html")) {
+		t.Fatalf("got %q, expected no warning for the small page", got)
+	}
+
+	// A run within the budget prints no warnings.
+	buf.Reset()
+	if err := (&Build{NoMinify: true, SizeBudget: 1000}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "warning") {
+		t.Fatalf("got %q, expected no warnings", buf.String())
+	}
+}
+
+func TestBuildNestedLayouts(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":       `<html>{{ block "content" . }}default:{{ .Current.Title }}{{ end }}</html>`,
+		"src/blog/layout.tmpl":  `{{ define "content" }}blog:{{ .Current.Title }}{{ end }}`,
+		"src/about/layout.tmpl": `standalone:{{ .Current.Title }}`,
+		"src/page.md":           "+++\ntitle = \"page\"\n+++\n",
+		"src/blog/post.md":      "+++\ntitle = \"post\"\n+++\n",
+		"src/blog/2016/old.md":  "+++\ntitle = \"old\"\n+++\n",
+		"src/notes/note.md":     "+++\ntitle = \"note\"\n+++\n",
+		"src/about/me.md":       "+++\ntitle = \"me\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{NoMinify: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name, expected string
+	}{
+		{"build/page/index.html", "<html>default:page</html>"},
+		{"build/blog/post/index.html", "<html>blog:post</html>"},
+		{"build/blog/2016/old/index.html", "<html>blog:old</html>"},
+		{"build/notes/note/index.html", "<html>default:note</html>"},
+		{"build/about/me/index.html", "standalone:me"},
+	}
+	for _, tc := range testcases {
+		if got := readFile(t, tc.name); got != tc.expected {
+			t.Fatalf("%s: got %q, expected %q", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildDraftsTo(t *testing.T) {
+	testcases := []struct {
+		draftsTo string
+		present  []string // Files expected in build.
+		absent   []string // Files expected not in build.
+		index    string   // Expected build/blog/index.html.
+	}{
+		{
+			"preview",
+			[]string{"build/blog/post/index.html", "build/preview/blog/draft/index.html"},
+			[]string{"build/blog/draft/index.html", "build/preview/blog/post/index.html"},
+			"/blog/post,",
+		},
+		{
+			"",
+			[]string{"build/blog/post/index.html"},
+			[]string{"build/blog/draft/index.html", "build/preview"},
+			"/blog/post,",
+		},
+	}
+
+	for _, tc := range testcases {
+		dir := t.TempDir()
+		writeTree(t, dir, map[string]string{
+			"src/blog/layout.tmpl": `{{ .Current.Path }}`,
+			"src/blog/index.html":  `{{ range .Dir }}{{ .Path }},{{ end }}`,
+			"src/blog/post.md":     "post",
+			"src/blog/draft.md":    "+++\ndraft = true\n+++\ndraft",
+		})
+		t.Chdir(dir)
+
+		if err := (&Build{DraftsTo: tc.draftsTo}).Run(); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range tc.present {
+			readFile(t, name)
+		}
+		for _, name := range tc.absent {
+			if _, err := os.Stat(filepath.FromSlash(name)); !os.IsNotExist(err) {
+				t.Fatalf("DraftsTo=%q: %s: expected not to exist, got err %v", tc.draftsTo, name, err)
+			}
+		}
+		if got := readFile(t, "build/blog/index.html"); got != tc.index {
+			t.Fatalf("DraftsTo=%q: build/blog/index.html: got %q, expected %q", tc.draftsTo, got, tc.index)
+		}
+		if tc.draftsTo != "" {
+			if got, expected := readFile(t, "build/preview/blog/draft/index.html"), "/preview/blog/draft"; got != expected {
+				t.Fatalf("DraftsTo=%q: draft Path: got %q, expected %q", tc.draftsTo, got, expected)
+			}
+		}
+	}
+}
+
+func TestBuildOutput(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":     `<html>{{ .Current.Title }}</html>`,
+		"src/layout.txt.tmpl": `{{ .Current.Title }} <{{ .Current.Path }}>`,
+		"src/page.md":         "+++\ntitle = \"page\"\n+++\n",
+		"src/notes/todo.md":   "+++\ntitle = \"a & b\"\noutput = \"txt\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{NoMinify: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := readFile(t, "build/page/index.html"), "<html>page</html>"; got != expected {
+		t.Fatalf("page: got %q, expected %q", got, expected)
+	}
+	if got, expected := readFile(t, "build/notes/todo.txt"), "a & b </notes/todo.txt>"; got != expected {
+		t.Fatalf("todo: got %q, expected %q", got, expected)
+	}
+	for _, name := range []string{"build/layout.txt.tmpl", "build/notes/todo/index.html"} {
+		if _, err := os.Stat(filepath.FromSlash(name)); !os.IsNotExist(err) {
+			t.Fatalf("%s: expected not to exist, got err %v", name, err)
+		}
+	}
+}
+
+func TestBuildLayout(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":        `<main>{{ .Current.Title }}</main>`,
+		"src/_layouts/wide.tmpl": `<main class="wide">{{ .Current.Title }}</main>`,
+		"src/blog/wide.tmpl":     `<main class="blog-wide">{{ .Current.Title }}</main>`,
+		"src/page.md":            "+++\ntitle = \"page\"\n+++\n",
+		"src/landing.md":         "+++\ntitle = \"landing\"\nlayout = \"wide.tmpl\"\n+++\n",
+		"src/blog/post.md":       "+++\ntitle = \"post\"\nlayout = \"wide.tmpl\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{NoMinify: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"build/page/index.html":      `<main>page</main>`,
+		"build/landing/index.html":   `<main class="wide">landing</main>`,
+		"build/blog/post/index.html": `<main class="blog-wide">post</main>`,
+	} {
+		if got := readFile(t, name); got != expected {
+			t.Fatalf("%s: got %q, expected %q", name, got, expected)
+		}
+	}
+	for _, name := range []string{"build/_layouts", "build/blog/wide.tmpl"} {
+		if _, err := os.Stat(filepath.FromSlash(name)); !os.IsNotExist(err) {
+			t.Fatalf("%s: expected not to exist, got err %v", name, err)
+		}
+	}
+
+	writeTree(t, dir, map[string]string{
+		"src/missing.md": "+++\nlayout = \"nope.tmpl\"\n+++\n",
+	})
+	err := (&Build{}).Run()
+	if err == nil || !strings.Contains(err.Error(), `missing layout "nope.tmpl"`) {
+		t.Fatalf("Run: got error %v, expected missing layout error", err)
+	}
+}
+
+func TestBuildRecent(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html":      `{{ range .Recent }}{{ .Title }} {{ end }}`,
+		"src/layout.tmpl":     "",
+		"src/about.md":        "+++\ntitle = \"about\"\ntime = \"2016-01-03\"\n+++\n",
+		"src/blog/a.md":       "+++\ntitle = \"a\"\ntime = \"2016-01-01\"\n+++\n",
+		"src/blog/b.md":       "+++\ntitle = \"b\"\ntime = \"2016-01-05\"\n+++\n",
+		"src/blog/draft.md":   "+++\ntitle = \"draft\"\ntime = \"2016-01-06\"\ndraft = true\n+++\n",
+		"src/notes/2016/c.md": "+++\ntitle = \"c\"\ntime = \"2016-01-04\"\n+++\n",
+		"src/notes/2016/d.md": "+++\ntitle = \"d\"\ntime = \"2016-01-02\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{NoMinify: true, Drafts: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := readFile(t, "build/index.html"), "b c about d a "; got != expected {
+		t.Fatalf("build/index.html: got %q, expected %q", got, expected)
+	}
+}
+
+func TestByTime(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2016, time.January, d, 0, 0, 0, 0, time.UTC) }
+	pages := []*Page{
+		{Title: "a", Path: "/a", Time: day(1)},
+		{Title: "b", Path: "/b", Time: day(2)},
+		{Title: "c", Path: "/c", Time: day(2)},
+		{Title: "d", Path: "/d", Time: day(2)},
+		{Title: "e", Path: "/e", Time: day(3)},
+	}
+	expected := []string{"e", "b", "c", "d", "a"}
+
+	// Sort every rotation and the reverse of every rotation, as if the
+	// pages were collected in different orders.
+	for i := range pages {
+		for _, reverse := range []bool{false, true} {
+			in := append(append([]*Page{}, pages[i:]...), pages[:i]...)
+			if reverse {
+				for l, r := 0, len(in)-1; l < r; l, r = l+1, r-1 {
+					in[l], in[r] = in[r], in[l]
+				}
+			}
+			sort.Sort(ByTime(in))
+			if got := titles(in); !reflect.DeepEqual(got, expected) {
+				t.Fatalf("ByTime: got %v, expected %v", got, expected)
+			}
+		}
+	}
+}
+
+func TestPageExcerpt(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		content  template.HTML
+		expected template.HTML
+	}{
+		{"<p>one</p>\n\n<p>two</p>\n", "<p>one</p>"},
+		{"<p>one</p>\n\n<p>two</p>\n\n<!--more-->\n\n<p>three</p>\n", "<p>one</p>\n\n<p>two</p>"},
+		{"<pre><code>&lt;!--more--&gt;\n</code></pre>\n\n<p>one</p>\n", "<p>one</p>"},
+		{"", ""},
+	}
+
+	for _, tc := range testcases {
+		p := &Page{Content: tc.content, Summary: summarize(tc.content)}
+		if got := p.Excerpt(); got != tc.expected {
+			t.Fatalf("Excerpt of %q: got %q, expected %q", tc.content, got, tc.expected)
+		}
+	}
+}
+
+func TestSplitMore(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		content       template.HTML
+		summary, rest template.HTML
+		ok            bool
+	}{
+		{"<p>one</p>\n", "", "<p>one</p>\n", false},
+		{"<p>one</p>\n\n<!--more-->\n\n<p>two</p>\n", "<p>one</p>", "<p>one</p>\n\n<p>two</p>\n", true},
+		{"<p>one</p>\n<!--more-->\n<p>two</p>\n<!--more-->\n", "<p>one</p>", "<p>one</p>\n<p>two</p>\n<!--more-->\n", true},
+		{"<pre><code>&lt;!--more--&gt;\n</code></pre>\n", "", "<pre><code>&lt;!--more--&gt;\n</code></pre>\n", false},
+	}
+
+	for _, tc := range testcases {
+		summary, rest, ok := splitMore(tc.content)
+		if summary != tc.summary || rest != tc.rest || ok != tc.ok {
+			t.Fatalf("splitMore(%q): got %q, %q, %t, expected %q, %q, %t", tc.content, summary, rest, ok, tc.summary, tc.rest, tc.ok)
+		}
+	}
+}
+
+func TestBuildMore(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": "{{ .Current.Summary }}|{{ .Current.Content }}",
+		"src/a.md":        "One.\n\nTwo.\n\n<!--more-->\n\nThree.\n",
+		"src/b.md":        "One.\n\nTwo.\n",
+		"src/c.md":        "One.\n\n```\n<!--more-->\n```\n\nTwo.\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{NoMinify: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name     string
+		expected string
+	}{
+		{"build/a/index.html", "<p>One.</p>\n\n<p>Two.</p>|<p>One.</p>\n\n<p>Two.</p>\n\n<p>Three.</p>\n"},
+		{"build/b/index.html", "<p>One.</p>|<p>One.</p>\n\n<p>Two.</p>\n"},
+		{"build/c/index.html", "<p>One.</p>|<p>One.</p>\n\n<pre><code>&lt;!--more--&gt;\n</code></pre>\n\n<p>Two.</p>\n"},
+	}
+
+	for _, tc := range testcases {
+		if got := readFile(t, tc.name); got != tc.expected {
+			t.Fatalf("%s: got %q, expected %q", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildExcerpts(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html":  `{{ range .Dir }}[{{ .Excerpt }}]{{ end }}`,
+		"src/layout.tmpl": "{{ .Current.Content }}",
+		"src/a.md":        "+++\ntitle = \"a\"\ntime = \"2016-01-01\"\n+++\nOne.\n\nTwo.\n",
+		"src/b.md":        "+++\ntitle = \"b\"\ntime = \"2016-01-02\"\n+++\nOne.\n\nTwo.\n\n<!--more-->\n\nThree.\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{NoMinify: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := readFile(t, "build/index.html"), "[<p>One.</p>\n\n<p>Two.</p>][<p>One.</p>]"; got != expected {
+		t.Fatalf("build/index.html: got %q, expected %q", got, expected)
+	}
+}
+
+func TestBuildRenderPage(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/css/style.css":    "a { color: red; }",
+		"src/layout.tmpl":      `<title>{{ .Current.Title }}</title>{{ .Current.Content }}`,
+		"src/blog/layout.tmpl": `<link href="{{ fingerprint "/css/style.css" }}" integrity="{{ sri "/css/style.css" }}"><article>{{ .Current.Content }}</article>`,
+		"src/blog/post.md":     "+++\ntitle = \"Post\"\n+++\n# Hello\n",
+		"src/about.md":         "About",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{Fingerprint: true, RSS: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := readFile(t, "build/blog/post/index.html")
+	if strings.Contains(expected, "/css/style.css") || !strings.Contains(expected, "sha384-") {
+		t.Fatalf("build/blog/post/index.html: got %q, expected a fingerprinted name and integrity value", expected)
+	}
+	if err := os.RemoveAll("build"); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.Buffer{}
+	if err := (&Build{Fingerprint: true, RSS: true}).RenderPage("src/blog/post.md", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != expected {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+	if _, err := os.Stat("build"); !os.IsNotExist(err) {
+		t.Fatalf("build: expected not to exist, got err %v", err)
+	}
+
+	for _, p := range []string{"src/layout.tmpl", "src/missing.md"} {
+		if err := (&Build{}).RenderPage(p, &buf); err == nil {
+			t.Fatalf("%s: expected error", p)
+		}
+	}
+}
+
+func TestBuildUnchangedOutputs(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `<p>{{ .Current.Title }}</p>`,
+		"src/index.html":  `<p>home</p>`,
+		"src/style.css":   `body { color: red; }`,
+		"src/img.bin":     "data",
+		"src/a.md":        "+++\ntitle = \"a\"\n+++\n",
+		"src/b.md":        "+++\ntitle = \"b\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	b := &Build{Robots: true, Compress: true}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set an old modification time on every output, which a rewrite
+	// would change.
+	old := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var outputs []string
+	err := filepath.Walk("build", func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		outputs = append(outputs, p)
+		return os.Chtimes(p, old, old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTree(t, dir, map[string]string{"src/b.md": "+++\ntitle = \"B\"\n+++\n"})
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := map[string]bool{
+		filepath.Join("build", "b", "index.html"):    true,
+		filepath.Join("build", "b", "index.html.gz"): true,
+	}
+	for _, p := range outputs {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := !info.ModTime().Equal(old); got != changed[p] {
+			t.Fatalf("%s: got modified %t, expected %t", p, got, changed[p])
+		}
+	}
+	if got, expected := readFile(t, "build/b/index.html"), "<p>B"; got != expected {
+		t.Fatalf("build/b/index.html: got %q, expected %q", got, expected)
+	}
+}
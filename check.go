@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Check validates the site in the source directory without writing
+// anything. It parses the front matter and template of each markdown
+// file, and each layout, partial, and .html template. The problems in
+// all files are returned at once as BuildErrors.
+type Check struct {
+	// Funcs is the list of plugins applied
+	// on markdown files.
+	Funcs texttemplate.FuncMap
+
+	Src string // Source directory. If empty, "src" is used.
+
+	// FrontMatterSep is the separator between the front matter and
+	// content of markdown files. If empty, FrontMatterSep is used.
+	FrontMatterSep string
+}
+
+func (c *Check) Run() error {
+	b := &Build{Src: c.Src}
+	src := b.srcDir()
+	funcs := b.layoutFuncs(&assetNames{}, nil, nil)
+
+	var errs BuildErrors
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && p == filepath.Join(src, ArchetypesDir) {
+			return filepath.SkipDir
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if err := c.checkFile(src, p, funcs); err != nil {
+			errs = append(errs, &fileError{p, err})
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errs.err()
+}
+
+// checkFile parses the file p, if it is a markdown file or a template,
+// and returns the first problem found.
+func (c *Check) checkFile(src, p string, funcs template.FuncMap) error {
+	name := filepath.Base(p)
+
+	switch {
+	case MarkdownExts[filepath.Ext(p)]:
+		contents, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		fm := FrontMatter{}
+		if err := fm.Parse(bytes.NewReader(contents), c.FrontMatterSep); err != nil && err != ErrNoFrontMatter {
+			return err
+		}
+		if fm.Layout != "" {
+			if _, err := namedLayoutFile(src, filepath.Dir(p), fm.Layout); err != nil {
+				return err
+			}
+		}
+		_, err = texttemplate.New("content").Funcs(c.Funcs).Parse(string(contents))
+		return err
+
+	case scssExts[filepath.Ext(p)] && !strings.HasPrefix(name, "_"):
+		_, err := compileSCSS(p)
+		return err
+
+	case name == DirConfigFile:
+		_, err := loadDirConfig(filepath.Dir(p))
+		return err
+
+	case isLayout(name) && name != layoutName(""):
+		// Layouts for other outputs are text templates.
+		_, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(funcs)).ParseFiles(p)
+		return err
+
+	case filepath.Ext(p) == ".tmpl", filepath.Ext(p) == ".html":
+		// HTML layouts, including those named in front matter,
+		// partials, and .html files.
+		_, err := template.New(name).Funcs(funcs).ParseFiles(p)
+		return err
+	}
+	return nil
+}
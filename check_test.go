@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		files    map[string]string
+		expected []string // Substrings of the error, one per failing file.
+	}{
+		{
+			map[string]string{
+				"src/layout.tmpl":         `<html>{{ .Current.Content }}{{ formatTime .BuildTime "date" }}</html>`,
+				"src/layout.txt.tmpl":     `{{ .Current.Title }}`,
+				"src/_partials/head.tmpl": `{{ define "head" }}<title>{{ . }}</title>{{ end }}`,
+				"src/index.html":          `{{ template "head" "home" }}{{ range .Dir }}{{ .Title }}{{ end }}`,
+				"src/post.md":             "+++\ntitle = \"post\"\ntime = \"2016-01-02\"\n+++\n{{ Tweet \"user\" \"123\" }}",
+				"src/style.css":           "{{ not a template",
+			},
+			nil,
+		},
+		{
+			map[string]string{
+				"src/layout.tmpl":     `<html>{{ .Current.Content </html>`,
+				"src/blog/bad.md":     "+++\ntime = \"yesterday\"\n+++\n",
+				"src/blog/broken.md":  "{{ if }}",
+				"src/blog/index.html": `{{ unknownFunc }}`,
+				"src/ok.md":           "+++\ntitle = \"ok\"\n+++\n",
+				"src/wide.md":         "+++\nlayout = \"wide.tmpl\"\n+++\n",
+				"src/_layouts/x.tmpl": `{{ if }}`,
+			},
+			[]string{
+				"src/layout.tmpl: ",
+				`src/blog/bad.md: line 2: key "time" has invalid value "yesterday"`,
+				"src/blog/broken.md: ",
+				`src/blog/index.html: `,
+				`src/wide.md: missing layout "wide.tmpl"`,
+				"src/_layouts/x.tmpl: ",
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		dir := t.TempDir()
+		writeTree(t, dir, tc.files)
+
+		err := (&Check{Funcs: DefaultFuncs(), Src: filepath.Join(dir, "src")}).Run()
+		if len(tc.expected) == 0 {
+			if err != nil {
+				t.Fatalf("Run: got %v, expected no error", err)
+			}
+			continue
+		}
+		var errs BuildErrors
+		if !errors.As(err, &errs) || len(errs) != len(tc.expected) {
+			t.Fatalf("Run: got %v, expected %d errors", err, len(tc.expected))
+		}
+		for _, s := range tc.expected {
+			if !strings.Contains(err.Error(), s) {
+				t.Fatalf("Run: got %q, expected it to contain %q", err, s)
+			}
+		}
+	}
+}
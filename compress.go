@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// compressExts is the extensions of generated files that are
+// precompressed when Build.Compress is true.
+var compressExts = map[string]bool{
+	".html": true,
+	".css":  true,
+	".js":   true,
+	".svg":  true,
+	".json": true,
+	".xml":  true,
+	".txt":  true,
+}
+
+// compressFile writes a gzip-compressed copy of the named file to the
+// same name with ".gz" appended.
+func compressFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	buf := bytes.Buffer{}
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(zw, in); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err = writeIfChanged(name+".gz", buf.Bytes())
+	return err
+}
+
+// precompressed returns a handler that serves the ".gz" sibling of the
+// requested file in dir, if it exists and the client accepts gzip.
+// Otherwise the request is served by h.
+func precompressed(dir string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		// Requests for index.html are redirected by http.FileServer.
+		if !acceptsGzip(r) || strings.HasSuffix(r.URL.Path, "/index.html") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		p := path.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") {
+			p = path.Join(p, "index.html")
+		}
+		name := filepath.Join(dir, filepath.FromSlash(p))
+		f, err := os.Open(name + ".gz")
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if ctype := mime.TypeByExtension(filepath.Ext(name)); ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		http.ServeContent(w, r, filepath.Base(name), info.ModTime(), f)
+	})
+}
+
+// acceptsGzip returns whether the request's Accept-Encoding header
+// includes gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(v, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+		for _, p := range params[1:] {
+			if q := strings.TrimSpace(p); q == "q=0" || q == "q=0.0" || q == "q=0.00" || q == "q=0.000" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
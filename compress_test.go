@@ -0,0 +1,111 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressFile(t *testing.T) {
+	t.Parallel()
+
+	const data = "<p>hello, hello, hello, hello</p>"
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"index.html": data})
+	name := filepath.Join(dir, "index.html")
+
+	if err := compressFile(name); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Fatalf("compressFile: got %q, expected %q", got, data)
+	}
+}
+
+func TestPrecompressed(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"index.html":      "<p>home</p>",
+		"style.css":       "a{color:red}",
+		"plain/app.js":    "console.log(1)",
+		"blog/index.html": "<p>blog</p>",
+	})
+	for _, name := range []string{"index.html", "style.css", "blog/index.html"} {
+		if err := compressFile(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := httptest.NewServer(precompressed(dir, http.FileServer(http.Dir(dir))))
+	defer ts.Close()
+
+	testcases := []struct {
+		path, acceptEncoding string
+		gzip                 bool
+		ctype, expected      string
+	}{
+		{"/style.css", "gzip, deflate", true, "text/css; charset=utf-8", "a{color:red}"},
+		{"/style.css", "", false, "text/css; charset=utf-8", "a{color:red}"},
+		{"/style.css", "br", false, "text/css; charset=utf-8", "a{color:red}"},
+		{"/style.css", "br, gzip;q=0", false, "text/css; charset=utf-8", "a{color:red}"},
+		{"/", "gzip", true, "text/html; charset=utf-8", "<p>home</p>"},
+		{"/blog/", "gzip", true, "text/html; charset=utf-8", "<p>blog</p>"},
+		{"/plain/app.js", "gzip", false, "text/javascript; charset=utf-8", "console.log(1)"},
+	}
+
+	// Disable the transport's transparent decompression.
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	for _, tc := range testcases {
+		req, err := http.NewRequest("GET", ts.URL+tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := resp.Body
+		if tc.gzip {
+			if body, err = gzip.NewReader(resp.Body); err != nil {
+				t.Fatalf("%s: %s", tc.path, err)
+			}
+		}
+		b, err := ioutil.ReadAll(body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if enc := resp.Header.Get("Content-Encoding"); (enc == "gzip") != tc.gzip {
+			t.Fatalf("%s (Accept-Encoding %q): got Content-Encoding %q, expected gzip %t", tc.path, tc.acceptEncoding, enc, tc.gzip)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, strings.Split(tc.ctype, ";")[0]) {
+			t.Fatalf("%s: got Content-Type %q, expected %q", tc.path, ct, tc.ctype)
+		}
+		if string(b) != tc.expected {
+			t.Fatalf("%s: got %q, expected %q", tc.path, b, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultConfigFile is the name of the config file read from the
+// working directory.
+const DefaultConfigFile = "batsman.toml"
+
+// Config represents the optional config file.
+//
+// Example config file:
+//
+//	src = "src"
+//	out = "build"
+//	http = "localhost:8080"
+//	baseURL = "https://example.com"
+//	basePath = "/blog"
+//	title = "My site"
+//	description = "Notes on Go"
+//	author = "Jane Doe"
+//	jobs = 4
+//	pageSize = 10
+//	minify = true
+//	drafts = false
+//	rss = true
+//	jsonFeed = true
+//	atom = true
+//	feedFullContent = false
+//	sitemap = true
+//	headers = ["Cache-Control: no-store"]
+//	frontMatterDelimiter = "---"
+//
+//	[env.staging]
+//	baseURL = "https://staging.example.com"
+//	drafts = true
+//
+// The file is a subset of TOML: "key = value" lines, where values are
+// quoted strings, integers, or booleans. Lines starting with "#" are
+// comments. The values in an "[env.name]" section replace the values
+// above it when the environment name is selected with the -env flag.
+type Config struct {
+	Src         string // Source directory.
+	Out         string // Output directory.
+	HTTP        string // HTTP address to serve at.
+	BaseURL     string // Base URL of the site.
+	BasePath    string // Path the site is deployed under, such as "/blog".
+	Title       string // Title of the site.
+	Description string // Description of the site.
+	Author      string // Author of the site.
+	Jobs        int    // Maximum number of files processed concurrently.
+	PageSize    int    // Number of markdown pages per page in directory indexes.
+	Minify      bool   // Whether to minify generated files.
+	Drafts      *bool  // Whether to include drafts; nil means the default for the command.
+
+	RSS             bool // Whether to write an RSS feed.
+	JSONFeed        bool // Whether to write a JSON feed.
+	Atom            bool // Whether to write an Atom feed.
+	FeedFullContent bool // Whether feeds contain full content rather than summaries.
+	Sitemap         bool // Whether to write a sitemap.
+
+	// FrontMatterSep is the separator around the front matter of
+	// markdown files, one of FrontMatterSeps. If empty,
+	// FrontMatterSep is used.
+	FrontMatterSep string
+
+	// Headers is the "Name: Value" headers added to served responses.
+	Headers []string
+}
+
+// defaultConfig is the config used for values absent from the
+// config file.
+var defaultConfig = Config{
+	Src:      "src",
+	Out:      "build",
+	HTTP:     "localhost:8080",
+	PageSize: 10,
+	Minify:   true,
+}
+
+// envPrefix is the prefix of the config file sections for environments.
+const envPrefix = "env."
+
+// LoadConfig reads the config file at path. Values absent from the
+// file are set to their defaults. If env is not empty, the values in the
+// file's section for the environment env replace the other values; an
+// environment without a section is an error. A missing file is not an
+// error; the default config is returned.
+func LoadConfig(path, env string) (Config, error) {
+	c := defaultConfig
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if env != "" {
+				return c, fmt.Errorf("unknown environment %q: config file %s does not exist", env, path)
+			}
+			return c, nil
+		}
+		return c, err
+	}
+	defer f.Close()
+
+	m, err := parseConfig(f)
+	if err != nil {
+		return c, fmt.Errorf("%s: %s", path, err)
+	}
+
+	// Separate the environment sections, and check that each is valid
+	// even if it is not selected.
+	base := make(map[string]string)
+	envs := make(map[string]map[string]string)
+	for k, v := range m {
+		if !strings.HasPrefix(k, envPrefix) || !strings.Contains(k[len(envPrefix):], ".") {
+			base[k] = v
+			continue
+		}
+		i := strings.LastIndex(k, ".")
+		name := k[len(envPrefix):i]
+		if envs[name] == nil {
+			envs[name] = make(map[string]string)
+		}
+		envs[name][k[i+1:]] = v
+	}
+	for name, em := range envs {
+		var scratch Config
+		if err := scratch.fromMap(em); err != nil {
+			return c, fmt.Errorf("%s: [%s%s]: %s", path, envPrefix, name, err)
+		}
+	}
+
+	if err := c.fromMap(base); err != nil {
+		return c, fmt.Errorf("%s: %s", path, err)
+	}
+	if env != "" {
+		em, ok := envs[env]
+		if !ok {
+			return c, fmt.Errorf("%s: unknown environment %q", path, env)
+		}
+		// Validated above.
+		c.fromMap(em)
+	}
+	return c, nil
+}
+
+func (c *Config) fromMap(m map[string]string) error {
+	for k, v := range m {
+		switch k {
+		case "src":
+			c.Src = v
+		case "out":
+			c.Out = v
+		case "http":
+			c.HTTP = v
+		case "baseURL":
+			c.BaseURL = v
+		case "basePath":
+			c.BasePath = v
+		case "title":
+			c.Title = v
+		case "description":
+			c.Description = v
+		case "author":
+			c.Author = v
+		case "jobs":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected integer", k, v)
+			}
+			c.Jobs = n
+		case "pageSize":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected integer", k, v)
+			}
+			c.PageSize = n
+		case "minify":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected true or false", k, v)
+			}
+			c.Minify = b
+		case "drafts":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected true or false", k, v)
+			}
+			c.Drafts = &b
+		case "rss":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected true or false", k, v)
+			}
+			c.RSS = b
+		case "jsonFeed":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected true or false", k, v)
+			}
+			c.JSONFeed = b
+		case "atom":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected true or false", k, v)
+			}
+			c.Atom = b
+		case "feedFullContent":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected true or false", k, v)
+			}
+			c.FeedFullContent = b
+		case "sitemap":
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected true or false", k, v)
+			}
+			c.Sitemap = b
+		case "headers":
+			headers, err := parseList(v)
+			if err != nil {
+				return fmt.Errorf("key %q has invalid value %q, expected list such as [\"Name: Value\"]", k, v)
+			}
+			c.Headers = headers
+		case "frontMatterDelimiter":
+			if !isValidFrontMatterSep(v) {
+				return fmt.Errorf("key %q has invalid value %q, expected one of %q", k, v, FrontMatterSeps)
+			}
+			c.FrontMatterSep = v
+		default:
+			return fmt.Errorf("unknown key %q", k)
+		}
+	}
+	return nil
+}
+
+// parseConfig parses "key = value" lines in r into a map. Quoted string
+// values are unquoted. The keys after a "[section]" line are prefixed
+// with "section.".
+func parseConfig(r io.Reader) (map[string]string, error) {
+	m := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty section name", n)
+			}
+			section = name + "."
+			continue
+		}
+
+		res := strings.SplitN(line, "=", 2)
+		if len(res) != 2 {
+			return nil, fmt.Errorf("line %d: %q should be in format \"key = value\"", n, line)
+		}
+		key, val := strings.TrimSpace(res[0]), strings.TrimSpace(res[1])
+		if strings.HasPrefix(val, `"`) {
+			s, err := strconv.Unquote(val)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string %s", n, val)
+			}
+			val = s
+		}
+		m[section+key] = val
+	}
+	return m, scanner.Err()
+}
+
+// override sets config values from the flags in fs that were set
+// explicitly, so that flags take precedence over the config file. The
+// "port" flag replaces the port in the HTTP address.
+func (c *Config) override(fs *flag.FlagSet) {
+	port := ""
+	fs.Visit(func(f *flag.Flag) {
+		v := f.Value.(flag.Getter).Get()
+		switch f.Name {
+		case "src":
+			c.Src = v.(string)
+		case "out":
+			c.Out = v.(string)
+		case "http":
+			c.HTTP = v.(string)
+		case "port":
+			port = strconv.Itoa(v.(int))
+		case "baseurl":
+			c.BaseURL = v.(string)
+		case "basepath":
+			c.BasePath = v.(string)
+		case "jobs":
+			c.Jobs = v.(int)
+		case "pagesize":
+			c.PageSize = v.(int)
+		case "minify":
+			c.Minify = v.(bool)
+		case "drafts":
+			drafts := v.(bool)
+			c.Drafts = &drafts
+		case "rss":
+			c.RSS = v.(bool)
+		case "jsonfeed":
+			c.JSONFeed = v.(bool)
+		case "atom":
+			c.Atom = v.(bool)
+		case "rssfull":
+			c.FeedFullContent = v.(bool)
+		case "sitemap":
+			c.Sitemap = v.(bool)
+		case "header":
+			c.Headers = v.([]string)
+		}
+	})
+
+	if port != "" {
+		host, _, err := net.SplitHostPort(c.HTTP)
+		if err != nil {
+			host = c.HTTP
+		}
+		c.HTTP = net.JoinHostPort(host, port)
+	}
+}
+
+// apply sets the fields of b that the config file controls, other than
+// Src and Out. Drafts is only set if c.Drafts is non-nil.
+func (c *Config) apply(b *Build) {
+	b.Jobs = c.Jobs
+	b.BaseURL = c.BaseURL
+	b.BasePath = c.BasePath
+	b.Title = c.Title
+	b.Description = c.Description
+	b.Author = c.Author
+	b.FrontMatterSep = c.FrontMatterSep
+	b.PageSize = c.PageSize
+	b.NoMinify = !c.Minify
+	b.RSS = c.RSS
+	b.JSONFeed = c.JSONFeed
+	b.Atom = c.Atom
+	b.FeedFullContent = c.FeedFullContent
+	b.Sitemap = c.Sitemap
+	if c.Drafts != nil {
+		b.Drafts = *c.Drafts
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"batsman.toml": `# comment
+src = "content"
+http = "localhost:9000"
+baseURL = "https://example.com"
+basePath = "/blog"
+description = "Notes on Go"
+author = "Jane Doe"
+jobs = 4
+frontMatterDelimiter = "---"
+rss = true
+atom = true
+feedFullContent = true
+sitemap = true
+`,
+		"bad.toml":     "src\n",
+		"unknown.toml": `foo = "bar"`,
+		"badint.toml":  `jobs = "four"`,
+		"badsep.toml":  `frontMatterDelimiter = "==="`,
+		"badbool.toml": `sitemap = "yes"`,
+	})
+
+	c, err := LoadConfig(filepath.Join(dir, "missing.toml"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, defaultConfig) {
+		t.Fatalf("missing config: got %+v, expected %+v", c, defaultConfig)
+	}
+
+	c, err = LoadConfig(filepath.Join(dir, "batsman.toml"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Config{
+		Src:         "content",
+		Out:         "build",
+		HTTP:        "localhost:9000",
+		BaseURL:     "https://example.com",
+		BasePath:    "/blog",
+		Description: "Notes on Go",
+		Author:      "Jane Doe",
+		Jobs:        4,
+		PageSize:    defaultConfig.PageSize,
+		Minify:      true,
+
+		RSS:             true,
+		Atom:            true,
+		FeedFullContent: true,
+		Sitemap:         true,
+
+		FrontMatterSep: "---",
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("LoadConfig: got %+v, expected %+v", c, expected)
+	}
+
+	for _, name := range []string{"bad.toml", "unknown.toml", "badint.toml", "badsep.toml", "badbool.toml"} {
+		if _, err := LoadConfig(filepath.Join(dir, name), ""); err == nil {
+			t.Fatalf("LoadConfig(%q): expected error", name)
+		}
+	}
+}
+
+func TestConfigOverride(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"batsman.toml": `src = "content"
+out = "public"
+baseURL = "https://example.com"
+rss = true
+atom = true
+sitemap = true
+`,
+	})
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("src", defaultConfig.Src, "")
+	fs.String("out", defaultConfig.Out, "")
+	fs.String("http", defaultConfig.HTTP, "")
+	fs.String("baseurl", "", "")
+	fs.Int("port", 0, "")
+	fs.Bool("rss", false, "")
+	fs.Bool("jsonfeed", false, "")
+	fs.Bool("atom", false, "")
+	fs.Bool("rssfull", false, "")
+	fs.Bool("sitemap", false, "")
+	if err := fs.Parse([]string{"-out", "dist", "-baseurl", "https://example.org/", "-port", "0", "-atom=false", "-rssfull"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadConfig(filepath.Join(dir, "batsman.toml"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.override(fs)
+
+	testcases := []struct {
+		name, got, expected string
+	}{
+		{"src (file over default)", c.Src, "content"},
+		{"out (flag over file)", c.Out, "dist"},
+		{"http (port flag over default)", c.HTTP, "localhost:0"},
+		{"baseURL (flag over file)", c.BaseURL, "https://example.org/"},
+		{"rss (file over default)", strconv.FormatBool(c.RSS), "true"},
+		{"jsonFeed (default)", strconv.FormatBool(c.JSONFeed), "false"},
+		{"atom (flag over file)", strconv.FormatBool(c.Atom), "false"},
+		{"feedFullContent (flag over default)", strconv.FormatBool(c.FeedFullContent), "true"},
+		{"sitemap (file over default)", strconv.FormatBool(c.Sitemap), "true"},
+	}
+
+	for _, tc := range testcases {
+		if tc.got != tc.expected {
+			t.Fatalf("%s: got %q, expected %q", tc.name, tc.got, tc.expected)
+		}
+	}
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"batsman.toml": `baseURL = "https://example.com"
+title = "Site"
+
+[env.staging]
+baseURL = "https://staging.example.com"
+minify = false
+drafts = true
+
+[env.production]
+drafts = false
+`,
+		"badenv.toml":     "[env.staging]\nminify = maybe\n",
+		"unknownkey.toml": "[env.staging]\nfoo = 1\n",
+		"badsection.toml": "[]\n",
+	})
+	path := filepath.Join(dir, "batsman.toml")
+	yes, no := true, false
+
+	testcases := []struct {
+		env      string
+		expected Config
+	}{
+		{"", Config{Src: "src", Out: "build", HTTP: "localhost:8080", BaseURL: "https://example.com", Title: "Site", PageSize: 10, Minify: true}},
+		{"staging", Config{Src: "src", Out: "build", HTTP: "localhost:8080", BaseURL: "https://staging.example.com", Title: "Site", PageSize: 10, Minify: false, Drafts: &yes}},
+		{"production", Config{Src: "src", Out: "build", HTTP: "localhost:8080", BaseURL: "https://example.com", Title: "Site", PageSize: 10, Minify: true, Drafts: &no}},
+	}
+
+	for _, tc := range testcases {
+		c, err := LoadConfig(path, tc.env)
+		if err != nil {
+			t.Fatalf("LoadConfig(%q): %s", tc.env, err)
+		}
+		drafts, expectedDrafts := c.Drafts, tc.expected.Drafts
+		c.Drafts, tc.expected.Drafts = nil, nil
+		if !reflect.DeepEqual(c, tc.expected) {
+			t.Fatalf("LoadConfig(%q): got %+v, expected %+v", tc.env, c, tc.expected)
+		}
+		if (drafts == nil) != (expectedDrafts == nil) || (drafts != nil && *drafts != *expectedDrafts) {
+			t.Fatalf("LoadConfig(%q): got Drafts %v, expected %v", tc.env, drafts, expectedDrafts)
+		}
+	}
+
+	// Flags take precedence over the environment.
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("baseurl", "", "")
+	if err := fs.Parse([]string{"-baseurl", "http://localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(path, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.override(fs)
+	if c.BaseURL != "http://localhost" {
+		t.Fatalf("baseURL (flag over env): got %q, expected %q", c.BaseURL, "http://localhost")
+	}
+
+	for _, tc := range []struct{ name, env, err string }{
+		{"batsman.toml", "dev", `unknown environment "dev"`},
+		{"missing.toml", "dev", `unknown environment "dev"`},
+		{"badenv.toml", "", `[env.staging]: key "minify" has invalid value "maybe"`},
+		{"unknownkey.toml", "", `[env.staging]: unknown key "foo"`},
+		{"badsection.toml", "", "empty section name"},
+	} {
+		_, err := LoadConfig(filepath.Join(dir, tc.name), tc.env)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("LoadConfig(%q, %q): got error %v, expected %q", tc.name, tc.env, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DataDir is the name of the source directory with data files that are
+// available to templates as .Data.
+const DataDir = "_data"
+
+// loadData reads the JSON and CSV files in dir, keyed by file name
+// without extension. JSON files decode to native values, such as
+// []interface{}; CSV files decode to []map[string]string, keyed by the
+// header row. Files with other extensions are ignored. A missing dir
+// results in an empty map.
+func loadData(dir string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+
+	for _, info := range infos {
+		ext := filepath.Ext(info.Name())
+		if info.IsDir() || (ext != ".json" && ext != ".csv") {
+			continue
+		}
+		p := filepath.Join(dir, info.Name())
+		key := strings.TrimSuffix(info.Name(), ext)
+		if _, ok := data[key]; ok {
+			return nil, fmt.Errorf("%s: duplicate data name %q", p, key)
+		}
+
+		var v interface{}
+		if ext == ".json" {
+			v, err = loadJSON(p)
+		} else {
+			v, err = loadCSV(p)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", p, err)
+		}
+		data[key] = v
+	}
+	return data, nil
+}
+
+func loadJSON(p string) (interface{}, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func loadCSV(p string) ([]map[string]string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, r := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, h := range header {
+			row[h] = r[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
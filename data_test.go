@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadData(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"projects.json": `[{"name": "batsman", "stars": 10}]`,
+		"people.csv":    "name,role\nAda,author\n\"Smith, J\",editor\n",
+		"notes.txt":     "ignored",
+	})
+
+	got, err := loadData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"projects": []interface{}{map[string]interface{}{"name": "batsman", "stars": float64(10)}},
+		"people": []map[string]string{
+			{"name": "Ada", "role": "author"},
+			{"name": "Smith, J", "role": "editor"},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("loadData: got %v, expected %v", got, expected)
+	}
+
+	if got, err := loadData(filepath.Join(dir, "missing")); err != nil || len(got) != 0 {
+		t.Fatalf("loadData: got %v, %v, expected empty map", got, err)
+	}
+
+	writeTree(t, dir, map[string]string{"people.json": `[]`})
+	if _, err := loadData(dir); err == nil || !strings.Contains(err.Error(), `duplicate data name "people"`) {
+		t.Fatalf("loadData: got error %v, expected duplicate data name", err)
+	}
+
+	bad := t.TempDir()
+	writeTree(t, bad, map[string]string{"x.json": `{`})
+	if _, err := loadData(bad); err == nil || !strings.Contains(err.Error(), "x.json") {
+		t.Fatalf("loadData: got error %v, expected it to name the file", err)
+	}
+}
+
+func TestBuildData(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/_data/projects.json": `[{"name": "a"}, {"name": "b"}]`,
+		"src/_data/people.csv":    "name,role\nAda,author\n",
+		"src/index.html":          `{{ range .Data.projects }}{{ .name }} {{ end }}{{ range .Data.people }}{{ .name }}: {{ .role }}{{ end }}`,
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := readFile(t, "build/index.html"), "a b Ada: author"; got != expected {
+		t.Fatalf("build/index.html: got %q, expected %q", got, expected)
+	}
+	if _, err := os.Stat("build/_data"); !os.IsNotExist(err) {
+		t.Fatalf("build/_data: got %v, expected not to exist", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DirConfigFile is the name of the optional file in a source directory
+// that sets defaults for the markdown files in the directory.
+const DirConfigFile = "_dir.toml"
+
+// DirConfig represents a DirConfigFile. Its values apply to the markdown
+// files in the directory, but not in subdirectories, whose front matter
+// does not set the same key.
+//
+// Example file:
+//
+//	titlePrefix = "Notes: "
+//	tags = ["notes"]
+//	output = "txt"
+//
+// The file uses the same format as the config file.
+type DirConfig struct {
+	TitlePrefix string   // Prepended to the title of each page.
+	Tags        []string // Tags of each page.
+	Output      string   // Output file extension of each page.
+}
+
+// loadDirConfig reads the DirConfigFile in dir. A missing file results
+// in the zero DirConfig.
+func loadDirConfig(dir string) (DirConfig, error) {
+	var c DirConfig
+	f, err := os.Open(filepath.Join(dir, DirConfigFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return c, err
+	}
+	defer f.Close()
+
+	m, err := parseConfig(f)
+	if err != nil {
+		return c, err
+	}
+	for k, v := range m {
+		switch k {
+		case "titlePrefix":
+			c.TitlePrefix = v
+		case "tags":
+			tags, err := parseList(v)
+			if err != nil {
+				return c, fmt.Errorf("key %q has invalid value %q, expected list such as [\"a\", \"b\"]", k, v)
+			}
+			c.Tags = tags
+		case "output":
+			fm := FrontMatter{}
+			if err := fm.fromMap(map[string]string{"output": v}); err != nil {
+				return c, err
+			}
+			c.Output = fm.Output
+		default:
+			return c, fmt.Errorf("unknown key %q", k)
+		}
+	}
+	return c, nil
+}
+
+// apply sets the fields of fm whose keys its front matter does not set
+// to the values in c.
+func (c DirConfig) apply(fm *FrontMatter) {
+	if !fm.keys["titlePrefix"] {
+		fm.TitlePrefix = c.TitlePrefix
+	}
+	if !fm.keys["tags"] {
+		fm.Tags = c.Tags
+	}
+	if !fm.keys["output"] {
+		fm.Output = c.Output
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadDirConfig(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		data     string
+		expected DirConfig
+		err      string // Substring of the error, if any.
+	}{
+		{"", DirConfig{}, ""},
+		{
+			"# Notes\ntitlePrefix = \"Notes: \"\ntags = [\"notes\", \"misc\"]\noutput = \"txt\"\n",
+			DirConfig{TitlePrefix: "Notes: ", Tags: []string{"notes", "misc"}, Output: "txt"},
+			"",
+		},
+		{"tags = notes\n", DirConfig{}, `key "tags" has invalid value`},
+		{"pageSize = 5\n", DirConfig{}, `unknown key "pageSize"`},
+	}
+
+	for _, tc := range testcases {
+		dir := t.TempDir()
+		writeTree(t, dir, map[string]string{DirConfigFile: tc.data})
+		got, err := loadDirConfig(dir)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("loadDirConfig %q: got err %v, expected %q", tc.data, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("loadDirConfig %q: %s", tc.data, err)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("loadDirConfig %q: got %+v, expected %+v", tc.data, got, tc.expected)
+		}
+	}
+
+	if got, err := loadDirConfig(t.TempDir()); err != nil || !reflect.DeepEqual(got, DirConfig{}) {
+		t.Fatalf("loadDirConfig of missing file: got %+v, %v; expected zero DirConfig", got, err)
+	}
+}
+
+func TestBuildDirConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":     `{{ .Current.Title }} {{ .Current.Tags }}`,
+		"src/blog/_dir.toml":  "titlePrefix = \"Blog: \"\ntags = [\"blog\"]\n",
+		"src/blog/a.md":       "+++\ntitle = \"a\"\n+++\n",
+		"src/blog/b.md":       "+++\ntitle = \"b\"\ntitlePrefix = \"\"\ntags = [\"other\"]\n+++\n",
+		"src/blog/c.md":       "no front matter",
+		"src/blog/2016/d.md":  "+++\ntitle = \"d\"\n+++\n",
+		"src/notes/_dir.toml": "titlePrefix = \"Note: \"\n",
+		"src/notes/e.md":      "+++\ntitle = \"e\"\ntitlePrefix = \"Draft note: \"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name, expected string
+	}{
+		{"build/blog/a/index.html", "Blog: a [blog]"},
+		{"build/blog/b/index.html", "b [other]"},
+		{"build/blog/c/index.html", "Blog: c [blog]"},
+		{"build/blog/2016/d/index.html", "d []"},
+		{"build/notes/e/index.html", "Draft note: e []"},
+	}
+	for _, tc := range testcases {
+		if got := readFile(t, tc.name); got != tc.expected {
+			t.Fatalf("%s: got %q, expected %q", tc.name, got, tc.expected)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("build", "blog", DirConfigFile)); !os.IsNotExist(err) {
+		t.Fatalf("%s: expected not to be copied, got err %v", DirConfigFile, err)
+	}
+}
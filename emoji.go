@@ -0,0 +1,91 @@
+package main
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// emojis is a map from shortcode names, as in ":rocket:", to emoji.
+var emojis = map[string]string{
+	"+1":                 "👍",
+	"-1":                 "👎",
+	"100":                "💯",
+	"bug":                "🐛",
+	"bulb":               "💡",
+	"check":              "✔️",
+	"clap":               "👏",
+	"coffee":             "☕",
+	"confused":           "😕",
+	"cry":                "😢",
+	"eyes":               "👀",
+	"fire":               "🔥",
+	"grin":               "😁",
+	"grinning":           "😀",
+	"heart":              "❤️",
+	"heavy_check_mark":   "✔️",
+	"hourglass":          "⌛",
+	"information_source": "ℹ️",
+	"joy":                "😂",
+	"laughing":           "😆",
+	"link":               "🔗",
+	"lock":               "🔒",
+	"memo":               "📝",
+	"ok_hand":            "👌",
+	"pencil":             "📝",
+	"point_right":        "👉",
+	"pray":               "🙏",
+	"question":           "❓",
+	"rocket":             "🚀",
+	"sad":                "😞",
+	"see_no_evil":        "🙈",
+	"smile":              "😄",
+	"smiley":             "😃",
+	"sparkles":           "✨",
+	"star":               "⭐",
+	"sunglasses":         "😎",
+	"tada":               "🎉",
+	"thinking":           "🤔",
+	"thumbsdown":         "👎",
+	"thumbsup":           "👍",
+	"warning":            "⚠️",
+	"wave":               "👋",
+	"white_check_mark":   "✅",
+	"wink":               "😉",
+	"wrench":             "🔧",
+	"x":                  "❌",
+	"zap":                "⚡",
+}
+
+// emojiSkipRe matches the parts of HTML in which shortcodes are not
+// replaced: pre and code elements, and tags.
+var emojiSkipRe = regexp.MustCompile(`(?s)<pre[\s>].*?</pre>|<code[\s>].*?</code>|<[^>]*>`)
+
+// shortcodeRe matches an emoji shortcode. The submatch is the name.
+var shortcodeRe = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+
+// replaceEmoji replaces the emoji shortcodes in the text of content,
+// such as ":rocket:", with the emoji. Unknown names, and shortcodes in
+// tags and in pre and code elements, are left as they are.
+func replaceEmoji(content template.HTML) template.HTML {
+	s := string(content)
+	var b strings.Builder
+	last := 0
+	for _, loc := range emojiSkipRe.FindAllStringIndex(s, -1) {
+		b.WriteString(replaceShortcodes(s[last:loc[0]]))
+		b.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(replaceShortcodes(s[last:]))
+	return template.HTML(b.String())
+}
+
+// replaceShortcodes replaces the known emoji shortcodes in text.
+func replaceShortcodes(text string) string {
+	return shortcodeRe.ReplaceAllStringFunc(text, func(m string) string {
+		if e, ok := emojis[m[1:len(m)-1]]; ok {
+			return e
+		}
+		return m
+	})
+}
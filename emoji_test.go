@@ -0,0 +1,28 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestReplaceEmoji(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in, expected template.HTML
+	}{
+		{"<p>Launch :rocket: now</p>", "<p>Launch 🚀 now</p>"},
+		{"<p>:tada::+1:</p>", "<p>🎉👍</p>"},
+		{"<p>Unknown :param: stays</p>", "<p>Unknown :param: stays</p>"},
+		{"<p>Use <code>:rocket:</code> for :rocket:</p>", "<p>Use <code>:rocket:</code> for 🚀</p>"},
+		{"<pre><code>x := :smile:\n</code></pre>\n<p>:smile:</p>", "<pre><code>x := :smile:\n</code></pre>\n<p>😄</p>"},
+		{`<p><a href="/a/:rocket:/b">:rocket:</a></p>`, `<p><a href="/a/:rocket:/b">🚀</a></p>`},
+		{"<p>10:30:45</p>", "<p>10:30:45</p>"},
+	}
+
+	for _, tc := range testcases {
+		if got := replaceEmoji(tc.in); got != tc.expected {
+			t.Fatalf("replaceEmoji %q: got %s, expected %s", tc.in, got, tc.expected)
+		}
+	}
+}
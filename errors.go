@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	"text/template"
+)
+
+// Exit codes of the batsman command.
+const (
+	ExitBuild = 1 // The build failed, such as for invalid front matter or a template error.
+	ExitUsage = 2 // The command line was invalid.
+	ExitIO    = 3 // A file could not be read or written.
+)
+
+// ExitError is an error that results in the exit code Code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// exitCode returns the exit code for err, the error returned by a Cmd.
+// It is 0 if err is nil, and the Code of an ExitError in err if there is
+// one. Otherwise build errors, such as template errors, result in
+// ExitBuild in preference to filesystem errors, which result in ExitIO.
+// Other errors result in ExitBuild.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var (
+		exitErr *ExitError
+		fmErr   *InvalidFrontMatterError
+		execErr template.ExecError
+		tmplErr *htmltemplate.Error
+		pathErr *fs.PathError
+	)
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.Code
+	case errors.As(err, &fmErr), errors.As(err, &execErr), errors.As(err, &tmplErr):
+		return ExitBuild
+	case errors.As(err, &pathErr), errors.Is(err, fs.ErrPermission), errors.Is(err, fs.ErrNotExist):
+		return ExitIO
+	}
+	return ExitBuild
+}
+
+// fileError is an error in the file at path.
+type fileError struct {
+	path string
+	err  error
+}
+
+func (e *fileError) Error() string { return e.path + ": " + e.err.Error() }
+
+func (e *fileError) Unwrap() error { return e.err }
+
+// BuildErrors is the errors from the files that failed to build. It is
+// returned by Build.Run so that every failing file is reported at once.
+type BuildErrors []error
+
+func (e BuildErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// Unwrap returns the errors, for use with errors.Is and errors.As.
+func (e BuildErrors) Unwrap() []error { return e }
+
+// err returns e, or nil if e is empty.
+func (e BuildErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
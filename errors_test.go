@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/a.html":   `{{ .Missing }`,
+		"src/b/c.html": `{{ end }}`,
+		"src/ok.html":  `ok`,
+	})
+	t.Chdir(dir)
+
+	err := (&Build{}).Run()
+	var errs BuildErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Run: got %v, expected BuildErrors", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Run: got %d errors, expected 2:\n%s", len(errs), err)
+	}
+	for _, name := range []string{filepath.Join("src", "a.html"), filepath.Join("src", "b", "c.html")} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("Run: got %q, expected to mention %s", err, name)
+		}
+	}
+}
+
+func TestMakePagesFileErrors(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name, contents string
+		expected       []string // Substrings of the error.
+	}{
+		{"foo.md", "{{ Nope }}", []string{"foo.md", `function "Nope" not defined`}},
+		{"bar.md", "+++\ntitle = \"bar\"\nmalformed\n+++\n", []string{"bar.md", "line 3:"}},
+		{"baz.md", "+++\ntitle = \"baz\"\ndraft = maybe\n+++\n", []string{"baz.md", "line 3:", `"draft"`}},
+	}
+
+	for _, tc := range testcases {
+		dir := t.TempDir()
+		writeTree(t, dir, map[string]string{tc.name: tc.contents})
+
+		_, _, _, err := (&Build{}).makePages(dir)
+		if err == nil {
+			t.Fatalf("%s: expected error", tc.name)
+		}
+		for _, s := range tc.expected {
+			if !strings.Contains(err.Error(), s) {
+				t.Fatalf("%s: got %q, expected to contain %q", tc.name, err, s)
+			}
+		}
+	}
+}
+
+func TestMakePagesFrontMatterAndTemplateErrors(t *testing.T) {
+	t.Parallel()
+
+	// Each file fails both to parse its front matter and to execute as a
+	// template. Many files make it likely that a template error is sent
+	// after the other results, which once panicked on the closed channel.
+	const n = 200
+	dir := t.TempDir()
+	files := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		files[fmt.Sprintf("%d.md", i)] = "+++\nbadline\n+++\n{{ nope }}"
+	}
+	writeTree(t, dir, files)
+
+	_, _, _, err := (&Build{}).makePages(dir)
+	var errs BuildErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("makePages: got %v, expected BuildErrors", err)
+	}
+	if len(errs) != 2*n {
+		t.Fatalf("makePages: got %d errors, expected %d", len(errs), 2*n)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `{{ .Current.Missing }}`,
+		"src/bad.md":      "+++\ndraft = maybe\n+++\n",
+		"src/page.md":     "hello",
+		"src/parse.html":  `{{ end }}`,
+	})
+	t.Chdir(dir)
+
+	var buf strings.Builder
+	stderr.SetOutput(&buf)
+	defer stderr.SetOutput(os.Stderr)
+
+	frontMatter := (&Build{}).Run()
+	os.Remove(filepath.Join("src", "bad.md"))
+	os.Remove(filepath.Join("src", "parse.html"))
+	execErr := (&Build{}).Run()
+
+	testcases := []struct {
+		err      error
+		expected int
+	}{
+		{nil, 0},
+		{errors.New("x"), ExitBuild},
+		{&ExitError{Code: ExitUsage, Err: errors.New("x")}, ExitUsage},
+		{&InvalidFrontMatterError{Key: "draft", Val: "maybe"}, ExitBuild},
+		{frontMatter, ExitBuild},
+		{execErr, ExitBuild},
+		{(&Build{Src: "missing"}).Run(), ExitIO},
+		{&fileError{"a", os.ErrPermission}, ExitIO},
+		{BuildErrors{errors.New("x"), &os.PathError{Op: "open", Path: "a", Err: os.ErrNotExist}}, ExitIO},
+		{BuildErrors{&os.PathError{Op: "open", Path: "a", Err: os.ErrNotExist}, &InvalidFrontMatterError{}}, ExitBuild},
+	}
+
+	for i, tc := range testcases {
+		if got := exit(tc.err); got != tc.expected {
+			t.Fatalf("%d: exit: got %d, expected %d for error %v", i, got, tc.expected, tc.err)
+		}
+	}
+	if !strings.Contains(buf.String(), "batsman: error: x") {
+		t.Fatalf("stderr: got %q, expected the error to be printed", buf.String())
+	}
+}
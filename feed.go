@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"sort"
+	"time"
+)
+
+// FeedFile is the name of the RSS feed file in the output directory.
+const FeedFile = "feed.xml"
+
+// JSONFeedFile is the name of the JSON feed file in the output
+// directory.
+const JSONFeedFile = "feed.json"
+
+// AtomFile is the name of the Atom feed file in the output directory.
+const AtomFile = "atom.xml"
+
+// Feed writes an RSS 2.0, JSON Feed 1.1, or Atom 1.0 feed of pages.
+type Feed struct {
+	Title   string // Title of the site.
+	BaseURL string // Base URL of the site, used to make absolute links.
+	Author  string // Author of the site. If empty, Title is used in Atom feeds.
+
+	// BuildTime is the time the build started. It is the updated
+	// time of an Atom feed without pages; if it is zero, the current
+	// time is used.
+	BuildTime time.Time
+
+	// FullContent indicates whether item descriptions are the full
+	// content of pages rather than their summaries.
+	FullContent bool
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        string  `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description rssData `xml:"description"`
+}
+
+// rssData is character data written in a CDATA section. encoding/xml
+// splits any "]]>" in the data across sections.
+type rssData struct {
+	Data string `xml:",cdata"`
+}
+
+// WriteRSS writes the feed for pages to w. Draft pages are excluded.
+func (f Feed) WriteRSS(w io.Writer, pages []*Page) error {
+	feed := rss{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        joinURL(f.BaseURL, "/"),
+			Description: f.Title,
+		},
+	}
+	for _, p := range pages {
+		if p.Draft {
+			continue
+		}
+		desc := p.Summary
+		if f.FullContent {
+			desc = p.Content
+		}
+		link := joinURL(f.BaseURL, p.Path)
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       p.Title,
+			Link:        link,
+			GUID:        link,
+			PubDate:     p.Time.Format(time.RFC1123Z),
+			Description: rssData{string(desc)},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// jsonFeed is a JSON Feed 1.1 document; see https://jsonfeed.org.
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	ContentHTML   string `json:"content_html"`
+	DatePublished string `json:"date_published"`
+}
+
+// WriteJSON writes the feed for pages to w as a JSON Feed. Draft pages
+// are excluded. URLs are made absolute with f.BaseURL.
+func (f Feed) WriteJSON(w io.Writer, pages []*Page) error {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: joinURL(f.BaseURL, "/"),
+		FeedURL:     joinURL(f.BaseURL, "/"+JSONFeedFile),
+		Items:       []jsonFeedItem{},
+	}
+	for _, p := range pages {
+		if p.Draft {
+			continue
+		}
+		content := p.Summary
+		if f.FullContent {
+			content = p.Content
+		}
+		link := joinURL(f.BaseURL, p.Path)
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            link,
+			URL:           link,
+			Title:         p.Title,
+			ContentHTML:   string(content),
+			DatePublished: p.Time.Format(time.RFC3339),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(feed)
+}
+
+// atom is an Atom 1.0 feed document; see RFC 4287.
+type atom struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Data string `xml:",chardata"`
+}
+
+// WriteAtom writes the feed for pages to w as an Atom feed. Draft pages
+// are excluded. The feed is updated at the time of its newest page, or
+// f.BuildTime if there are none. IDs are the absolute URLs of the site
+// and pages, made with f.BaseURL.
+func (f Feed) WriteAtom(w io.Writer, pages []*Page) error {
+	author := f.Author
+	if author == "" {
+		author = f.Title
+	}
+	feed := atom{
+		ID:     joinURL(f.BaseURL, "/"),
+		Title:  f.Title,
+		Author: atomAuthor{author},
+		Links: []atomLink{
+			{Href: joinURL(f.BaseURL, "/")},
+			{Rel: "self", Href: joinURL(f.BaseURL, "/"+AtomFile)},
+		},
+	}
+	var updated time.Time
+	for _, p := range pages {
+		if p.Draft {
+			continue
+		}
+		if p.Time.After(updated) {
+			updated = p.Time
+		}
+		content := p.Summary
+		if f.FullContent {
+			content = p.Content
+		}
+		link := joinURL(f.BaseURL, p.Path)
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      link,
+			Title:   p.Title,
+			Link:    atomLink{Href: link},
+			Updated: p.Time.Format(time.RFC3339),
+			Content: atomContent{Type: "html", Data: string(content)},
+		})
+	}
+	if updated.IsZero() {
+		updated = f.BuildTime
+		if updated.IsZero() {
+			updated = time.Now()
+		}
+	}
+	feed.Updated = updated.Format(time.RFC3339)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeFeed writes the feed for pages, newest first, to the named file
+// using write, such as Feed.WriteRSS, Feed.WriteJSON, or Feed.WriteAtom.
+func writeFeed(name string, write func(io.Writer, []*Page) error, pages map[string]*Page) error {
+	sorted := make([]*Page, 0, len(pages))
+	for _, p := range pages {
+		sorted = append(sorted, p)
+	}
+	sort.Sort(ByTime(sorted))
+
+	buf := bytes.Buffer{}
+	if err := write(&buf, sorted); err != nil {
+		return err
+	}
+	_, err := writeIfChanged(name, buf.Bytes())
+	return err
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedWriteRSS(t *testing.T) {
+	t.Parallel()
+
+	pages := []*Page{
+		{
+			Title:   "Hello",
+			Path:    "/blog/hello",
+			Time:    time.Date(2016, time.March, 4, 0, 0, 0, 0, time.UTC),
+			Summary: "<p>summary</p>",
+			Content: "<p>summary</p><script>if (a[b[0]]>1) {}</script>",
+		},
+		{Title: "Draft", Path: "/blog/draft", Draft: true},
+	}
+
+	testcases := []struct {
+		full     bool
+		expected string // Description of the first item.
+	}{
+		{false, "<p>summary</p>"},
+		{true, "<p>summary</p><script>if (a[b[0]]>1) {}</script>"},
+	}
+
+	for _, tc := range testcases {
+		buf := bytes.Buffer{}
+		f := Feed{Title: "Site", BaseURL: "https://example.com/", FullContent: tc.full}
+		if err := f.WriteRSS(&buf, pages); err != nil {
+			t.Fatal(err)
+		}
+		if tc.full && strings.Contains(buf.String(), "a[b[0]]>1") {
+			t.Fatalf("FullContent=%t: got unescaped \"]]>\" in %s", tc.full, buf.String())
+		}
+
+		var got rss
+		if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("FullContent=%t: %s", tc.full, err)
+		}
+		items := got.Channel.Items
+		if len(items) != 1 {
+			t.Fatalf("FullContent=%t: got %d items, expected 1", tc.full, len(items))
+		}
+		if items[0].Link != "https://example.com/blog/hello" {
+			t.Fatalf("FullContent=%t: got link %s, expected %s", tc.full, items[0].Link, "https://example.com/blog/hello")
+		}
+		if items[0].Description.Data != tc.expected {
+			t.Fatalf("FullContent=%t: got description %s, expected %s", tc.full, items[0].Description.Data, tc.expected)
+		}
+	}
+}
+
+func TestFeedWriteJSON(t *testing.T) {
+	t.Parallel()
+
+	pages := []*Page{
+		{
+			Title:   "Hello",
+			Path:    "/blog/hello",
+			Time:    time.Date(2016, time.March, 4, 15, 30, 0, 0, time.FixedZone("", 5*60*60+30*60)),
+			Summary: "<p>summary</p>",
+			Content: "<p>summary</p><p>more</p>",
+		},
+		{Title: "Draft", Path: "/blog/draft", Draft: true},
+	}
+
+	buf := bytes.Buffer{}
+	f := Feed{Title: "Site", BaseURL: "https://example.com/"}
+	if err := f.WriteJSON(&buf, pages); err != nil {
+		t.Fatal(err)
+	}
+
+	var got jsonFeed
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       "Site",
+		HomePageURL: "https://example.com/",
+		FeedURL:     "https://example.com/feed.json",
+		Items: []jsonFeedItem{{
+			ID:            "https://example.com/blog/hello",
+			URL:           "https://example.com/blog/hello",
+			Title:         "Hello",
+			ContentHTML:   "<p>summary</p>",
+			DatePublished: "2016-03-04T15:30:00+05:30",
+		}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("WriteJSON: got %+v, expected %+v", got, expected)
+	}
+	if _, err := time.Parse(time.RFC3339, got.Items[0].DatePublished); err != nil {
+		t.Fatalf("WriteJSON: date_published: %s", err)
+	}
+
+	// Items is an empty array, not null, when there are no pages.
+	buf.Reset()
+	if err := f.WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"items": []`) {
+		t.Fatalf("WriteJSON: got %s, expected empty items array", buf.String())
+	}
+}
+
+func TestBuildJSONFeed(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": "{{ .Current.Content }}",
+		"src/a.md":        "+++\ntitle = \"a\"\ntime = \"2016-01-01\"\n+++\nOne.\n\nTwo.\n",
+		"src/b.md":        "+++\ntitle = \"b\"\ntime = \"2016-01-02\"\n+++\nThree.\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{JSONFeed: true, FeedFullContent: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	var got jsonFeed
+	if err := json.Unmarshal([]byte(readFile(t, "build/feed.json")), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Items) != 2 || got.Items[0].Title != "b" || got.Items[1].ContentHTML != "<p>One.</p>\n\n<p>Two.</p>\n" {
+		t.Fatalf("build/feed.json: got %+v, expected b then a with full content", got.Items)
+	}
+}
+
+func TestFeedWriteAtom(t *testing.T) {
+	t.Parallel()
+
+	pages := []*Page{
+		{
+			Title:   "Newer",
+			Path:    "/blog/newer",
+			Time:    time.Date(2016, time.March, 5, 10, 0, 0, 0, time.UTC),
+			Summary: "<p>newer</p>",
+		},
+		{
+			Title:   "Older",
+			Path:    "/blog/older",
+			Time:    time.Date(2016, time.March, 4, 0, 0, 0, 0, time.UTC),
+			Summary: "<p>older</p>",
+		},
+		{Title: "Draft", Path: "/blog/draft", Time: time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC), Draft: true},
+	}
+
+	buf := bytes.Buffer{}
+	f := Feed{Title: "Site", BaseURL: "https://example.com/", Author: "Jane Doe"}
+	if err := f.WriteAtom(&buf, pages); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<feed xmlns="http://www.w3.org/2005/Atom">`) {
+		t.Fatalf("WriteAtom: got %s, expected Atom namespace", buf.String())
+	}
+
+	var got atom
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "https://example.com/" || got.Title != "Site" || got.Author.Name != "Jane Doe" {
+		t.Fatalf("WriteAtom: got id %q, title %q, author %q", got.ID, got.Title, got.Author.Name)
+	}
+	if got.Updated != "2016-03-05T10:00:00Z" {
+		t.Fatalf("WriteAtom: got updated %q, expected the newest page's time", got.Updated)
+	}
+	expected := []atomEntry{
+		{
+			ID:      "https://example.com/blog/newer",
+			Title:   "Newer",
+			Link:    atomLink{Href: "https://example.com/blog/newer"},
+			Updated: "2016-03-05T10:00:00Z",
+			Content: atomContent{Type: "html", Data: "<p>newer</p>"},
+		},
+		{
+			ID:      "https://example.com/blog/older",
+			Title:   "Older",
+			Link:    atomLink{Href: "https://example.com/blog/older"},
+			Updated: "2016-03-04T00:00:00Z",
+			Content: atomContent{Type: "html", Data: "<p>older</p>"},
+		},
+	}
+	if !reflect.DeepEqual(got.Entries, expected) {
+		t.Fatalf("WriteAtom: got entries %+v, expected %+v", got.Entries, expected)
+	}
+
+	// Without pages, the feed is updated at the build time.
+	buf.Reset()
+	f.BuildTime = time.Date(2016, time.April, 1, 12, 0, 0, 0, time.UTC)
+	if err := f.WriteAtom(&buf, pages[2:]); err != nil {
+		t.Fatal(err)
+	}
+	got = atom{}
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Updated != "2016-04-01T12:00:00Z" || len(got.Entries) != 0 {
+		t.Fatalf("WriteAtom without pages: got updated %q and %d entries, expected the build time and none", got.Updated, len(got.Entries))
+	}
+}
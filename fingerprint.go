@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// fingerprintLen is the number of hex digits of the content hash in
+// fingerprinted file names.
+const fingerprintLen = 8
+
+// fingerprintName returns name with a prefix of the hex SHA-256 of data
+// inserted before the extension. For example, "css/style.css" becomes
+// "css/style.0123abcd.css".
+func fingerprintName(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return trimExt(name) + "." + hex.EncodeToString(sum[:])[:fingerprintLen] + filepath.Ext(name)
+}
+
+// assetNames maps the HTTP paths of fingerprinted assets to their
+// fingerprinted HTTP paths, and caches the integrity values of assets
+// in dir. It is safe for concurrent use.
+type assetNames struct {
+	enabled bool   // Whether assets are fingerprinted.
+	dir     string // Build directory.
+
+	mx      sync.Mutex
+	m       map[string]string
+	digests map[string]string // HTTP path to sri value.
+}
+
+func (a *assetNames) add(p, fingerprinted string) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if a.m == nil {
+		a.m = make(map[string]string)
+	}
+	a.m[p] = fingerprinted
+}
+
+// fingerprint returns the fingerprinted HTTP path of the asset at HTTP
+// path p. If fingerprinting is disabled, p is returned unchanged.
+func (a *assetNames) fingerprint(p string) (string, error) {
+	if !a.enabled {
+		return p, nil
+	}
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	fp, ok := a.m[path.Clean("/"+p)]
+	if !ok {
+		return "", fmt.Errorf("fingerprint: no fingerprinted asset at %q", p)
+	}
+	return fp, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFingerprintName(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name, data, expected string
+	}{
+		{"css/style.css", "hello", "css/style.2cf24dba.css"},
+		{"app.min.js", "hello", "app.min.2cf24dba.js"},
+		{"noext", "hello", "noext.2cf24dba"},
+	}
+
+	for _, tc := range testcases {
+		if res := fingerprintName(tc.name, []byte(tc.data)); res != tc.expected {
+			t.Fatalf("fingerprintName(%q): got %q, expected %q", tc.name, res, tc.expected)
+		}
+	}
+}
+
+func TestAssetNames(t *testing.T) {
+	t.Parallel()
+
+	a := &assetNames{enabled: true}
+	a.add("/css/style.css", "/css/style.2cf24dba.css")
+	for _, p := range []string{"/css/style.css", "css/style.css"} {
+		res, err := a.fingerprint(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != "/css/style.2cf24dba.css" {
+			t.Fatalf("fingerprint(%q): got %q, expected %q", p, res, "/css/style.2cf24dba.css")
+		}
+	}
+	if _, err := a.fingerprint("/css/missing.css"); err == nil {
+		t.Fatal("fingerprint: expected error for unknown asset")
+	}
+
+	disabled := &assetNames{}
+	if res, err := disabled.fingerprint("/css/style.css"); err != nil || res != "/css/style.css" {
+		t.Fatalf("fingerprint (disabled): got %q, %v, expected %q", res, err, "/css/style.css")
+	}
+}
+
+func TestBuildFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/css/style.css": "a { color: red; }",
+		"src/index.html":    `{{ fingerprint "/css/style.css" }}`,
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{Fingerprint: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, "build/index.html"); got != "/css/style.ea159630.css" {
+		t.Fatalf("build/index.html: got %q, expected %q", got, "/css/style.ea159630.css")
+	}
+	if got := readFile(t, "build/css/style.ea159630.css"); got != "a{color:red}" {
+		t.Fatalf("build/css/style.ea159630.css: got %q, expected %q", got, "a{color:red}")
+	}
+	if _, err := os.Stat("build/css/style.css"); !os.IsNotExist(err) {
+		t.Fatalf("build/css/style.css: expected not to exist, got err %v", err)
+	}
+}
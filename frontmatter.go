@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -19,19 +21,44 @@ import (
 //   +++
 //   time = "2006-01-02 15:04:05 -07:00"
 //   title = "Hello, world"
+//   titlePrefix = "Blog: "
+//   description = "A first post"
+//   tags = ["hello", "world"]
+//   expiry = "2006-02-01"
+//   output = "txt"
+//   layout = "wide.tmpl"
+//   aliases = ["/old/path"]
+//   image = "/img/hello.png"
 //   draft = true
 //   +++
 //
 type FrontMatter struct {
-	Draft bool
-	Title string
-	Time  time.Time
+	Draft       bool
+	Title       string
+	Description string
+	Tags        []string
+	Time        time.Time
+	Expiry      time.Time // Time after which the page is omitted; zero means never.
+	Output      string    // Output file extension, such as "txt"; empty means HTML.
+	Layout      string    // Layout file name, such as "wide.tmpl"; empty means the directory's layout.
+	Aliases     []string  // Paths that redirect to the page.
+	TitlePrefix string    // Prepended to Title in the page title.
+
+	// Params holds the string values of keys that are not otherwise
+	// used, such as "image".
+	Params map[string]string
+
+	keys map[string]bool // Keys set in the parsed front matter.
 }
 
-// FrontMatterSep is the separator between front matter
+// FrontMatterSep is the default separator between front matter
 // and content.
 const FrontMatterSep = `+++`
 
+// FrontMatterSeps is the separators that may be configured in place
+// of FrontMatterSep, for sites migrated from other generators.
+var FrontMatterSeps = []string{FrontMatterSep, `---`, `;;;`}
+
 // FrontMatterSepBytes is FrontMatterSep as []byte.
 var FrontMatterSepBytes = []byte(FrontMatterSep)
 
@@ -39,29 +66,75 @@ var FrontMatterSepBytes = []byte(FrontMatterSep)
 const FrontMatterFieldSep = ` = `
 
 // KnownTimeFormats is the the accepted time formats for time
-// in front matter.
+// in front matter. Unix timestamps in seconds are also accepted.
 var KnownTimeFormats = []string{
 	"2006-01-02 15:04:05 -07:00",
 	"2006-01-02 15:04:05",
 	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
 }
 var defaultTimeFormat = KnownTimeFormats[0]
 
 // String returns a representation that matches the front matter
-// representation in a file.
+// representation in a file. The result is accepted by Parse.
 func (fm *FrontMatter) String() string {
+	return fm.format(FrontMatterSep)
+}
+
+// format is like String, but separates the front matter with sep.
+func (fm *FrontMatter) format(sep string) string {
 	buf := bytes.Buffer{}
-	buf.WriteString(FrontMatterSep + "\n")
+	field := func(key, val string) {
+		buf.WriteString(key + FrontMatterFieldSep + val + "\n")
+	}
+
+	buf.WriteString(sep + "\n")
 	if fm.Title != "" {
-		buf.WriteString(fmt.Sprintf("title%s%q\n", FrontMatterFieldSep, fm.Title))
+		field("title", strconv.Quote(fm.Title))
+	}
+	if fm.TitlePrefix != "" {
+		field("titlePrefix", strconv.Quote(fm.TitlePrefix))
+	}
+	if fm.Description != "" {
+		field("description", strconv.Quote(fm.Description))
+	}
+	if len(fm.Tags) > 0 {
+		tags := make([]string, len(fm.Tags))
+		for i, t := range fm.Tags {
+			tags[i] = strconv.Quote(t)
+		}
+		field("tags", "["+strings.Join(tags, ", ")+"]")
+	}
+	field("time", strconv.Quote(fm.Time.Format(defaultTimeFormat)))
+	if !fm.Expiry.IsZero() {
+		field("expiry", strconv.Quote(fm.Expiry.Format(defaultTimeFormat)))
+	}
+	if fm.Output != "" {
+		field("output", strconv.Quote(fm.Output))
+	}
+	if fm.Layout != "" {
+		field("layout", strconv.Quote(fm.Layout))
+	}
+	if len(fm.Aliases) > 0 {
+		aliases := make([]string, len(fm.Aliases))
+		for i, a := range fm.Aliases {
+			aliases[i] = strconv.Quote(a)
+		}
+		field("aliases", "["+strings.Join(aliases, ", ")+"]")
 	}
 	if fm.Draft {
-		buf.WriteString(fmt.Sprintf("draft%s%t\n", FrontMatterFieldSep, fm.Draft))
+		field("draft", "true")
+	}
+	params := make([]string, 0, len(fm.Params))
+	for k := range fm.Params {
+		params = append(params, k)
 	}
-	// TODO(nishanths): Manually added a space after time for aligning the
-	// separators. Should be refactored when more fields are added.
-	buf.WriteString(fmt.Sprintf("time %s%q\n", FrontMatterFieldSep, fm.Time.Format(defaultTimeFormat)))
-	buf.WriteString(FrontMatterSep + "\n")
+	sort.Strings(params)
+	for _, k := range params {
+		field(k, strconv.Quote(fm.Params[k]))
+	}
+	buf.WriteString(sep + "\n")
 	return buf.String()
 }
 
@@ -70,10 +143,14 @@ func (fm *FrontMatter) String() string {
 type InvalidFrontMatterError struct {
 	Key, Val    string
 	CorrectVals []string
+	Line        int // Line number in the file, if known.
 }
 
 func (e *InvalidFrontMatterError) Error() string {
 	s := fmt.Sprintf("key %q has invalid value %q", e.Key, e.Val)
+	if e.Line > 0 {
+		s = fmt.Sprintf("line %d: %s", e.Line, s)
+	}
 	if len(e.CorrectVals) > 0 {
 		s += fmt.Sprintf(
 			"\nexpected values/formats: {%s}", strings.Join(e.CorrectVals, ", "),
@@ -83,84 +160,267 @@ func (e *InvalidFrontMatterError) Error() string {
 }
 
 func (fm *FrontMatter) fromMap(m map[string]string) error {
-	v := m["draft"]
-	if v == "true" {
-		fm.Draft = true
-	} else if v != "" && v != "false" {
-		return &InvalidFrontMatterError{"draft", v, []string{"true", "false"}}
+	if v := m["draft"]; v != "" {
+		draft, err := parseBool(v)
+		if err != nil {
+			return &InvalidFrontMatterError{Key: "draft", Val: v, CorrectVals: []string{"true", "false"}}
+		}
+		fm.Draft = draft
 	}
 
 	fm.Title = m["title"]
+	fm.TitlePrefix = m["titlePrefix"]
+	fm.Description = m["description"]
+	fm.Output = strings.TrimPrefix(m["output"], ".")
+	if fm.Output == "html" {
+		fm.Output = ""
+	}
+	if strings.ContainsAny(fm.Output, `/\`) {
+		return &InvalidFrontMatterError{Key: "output", Val: m["output"], CorrectVals: []string{"file extension such as txt"}}
+	}
+	fm.Layout = m["layout"]
+	if strings.ContainsAny(fm.Layout, `/\`) {
+		return &InvalidFrontMatterError{Key: "layout", Val: m["layout"], CorrectVals: []string{"file name such as wide.tmpl"}}
+	}
+	if m["aliases"] != "" {
+		aliases, err := parseList(m["aliases"])
+		if err != nil {
+			return &InvalidFrontMatterError{Key: "aliases", Val: m["aliases"], CorrectVals: []string{`["/old/path"]`}}
+		}
+		fm.Aliases = aliases
+	}
+	if m["tags"] != "" {
+		tags, err := parseList(m["tags"])
+		if err != nil {
+			return &InvalidFrontMatterError{Key: "tags", Val: m["tags"], CorrectVals: []string{`["a", "b"]`}}
+		}
+		fm.Tags = tags
+	}
 
-	if m["time"] != "" {
-		for _, format := range KnownTimeFormats {
-			t, err := time.Parse(format, v)
-			if err == nil {
-				fm.Time = t
-				break
-			}
+	if v := m["time"]; v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return &InvalidFrontMatterError{Key: "time", Val: v, CorrectVals: KnownTimeFormats}
 		}
-		return &InvalidFrontMatterError{"time", v, KnownTimeFormats}
+		fm.Time = t
+	}
+	if v := m["expiry"]; v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return &InvalidFrontMatterError{Key: "expiry", Val: v, CorrectVals: KnownTimeFormats}
+		}
+		fm.Expiry = t
 	}
 
 	return nil
 }
 
+// parseTime parses s in the first matching format in KnownTimeFormats,
+// or as Unix seconds if s is all digits.
+func parseTime(s string) (time.Time, error) {
+	if isDigits(s) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	var err error
+	for _, format := range KnownTimeFormats {
+		var t time.Time
+		if t, err = time.Parse(format, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
 var ErrNoFrontMatter = errors.New("no front matter")
 
-// Parse parses front matter in r.
+// Parse parses front matter in r, separated from the content by sep.
+// If sep is empty, FrontMatterSep is used.
 // If r is empty or there is no front matter, the error
 // will be ErrNoFrontMatter.
-func (fm *FrontMatter) Parse(r io.Reader) error {
+func (fm *FrontMatter) Parse(r io.Reader, sep string) error {
 	scanner := bufio.NewScanner(r)
 	ok := scanner.Scan()
 	if !ok {
 		return ErrNoFrontMatter
 	}
-	first := scanner.Text()
-	if first != FrontMatterSep {
+	if !isFrontMatterSep(scanner.Text(), sep) {
 		return ErrNoFrontMatter
 	}
 
 	m := map[string]string{
-		"draft": "",
-		"title": "",
-		"time":  "",
+		"draft":       "",
+		"title":       "",
+		"description": "",
+		"tags":        "",
+		"time":        "",
+		"expiry":      "",
+		"output":      "",
+		"layout":      "",
+		"aliases":     "",
+		"titlePrefix": "",
 	}
 	clean := func(s string) string {
-		return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), `"`), `"`)
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, `"`) {
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		}
+		if len(s) >= 2 && strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") {
+			return s[1 : len(s)-1] // Literal string.
+		}
+		return strings.TrimSuffix(strings.TrimPrefix(s, `"`), `"`)
 	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == FrontMatterSep {
+	known := make(map[string]bool, len(m))
+	for k := range m {
+		known[k] = true
+	}
+	lines := make(map[string]int) // Line number of each key.
+
+	for n := 2; scanner.Scan(); n++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if isFrontMatterSep(line, sep) {
 			break // End of front matter.
 		}
 
 		res := strings.SplitN(line, FrontMatterFieldSep, 2)
 		if len(res) != 2 {
-			return fmt.Errorf("front matter %q should be in format \"key%sval\"", line, FrontMatterFieldSep)
+			return fmt.Errorf("line %d: front matter %q should be in format \"key%sval\"", n, line, FrontMatterFieldSep)
 		}
 		key, val := clean(res[0]), clean(res[1])
 		m[key] = val
+		lines[key] = n
+	}
+
+	fm.keys = make(map[string]bool, len(lines))
+	for k := range lines {
+		fm.keys[k] = true
+		if !known[k] {
+			if fm.Params == nil {
+				fm.Params = make(map[string]string)
+			}
+			fm.Params[k] = m[k]
+		}
+	}
+	err := fm.fromMap(m)
+	if e, ok := err.(*InvalidFrontMatterError); ok {
+		e.Line = lines[e.Key]
+	}
+	return err
+}
+
+// isFrontMatterSep returns whether line, without its newline, is sep,
+// or FrontMatterSep if sep is empty. A trailing "\r", as in files with
+// Windows line endings, is ignored.
+func isFrontMatterSep(line, sep string) bool {
+	if sep == "" {
+		sep = FrontMatterSep
+	}
+	return strings.TrimSuffix(line, "\r") == sep
+}
+
+// isValidFrontMatterSep reports whether sep is in FrontMatterSeps.
+func isValidFrontMatterSep(sep string) bool {
+	for _, s := range FrontMatterSeps {
+		if sep == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBool parses a TOML boolean, true or false.
+func parseBool(s string) (bool, error) {
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
 	}
+	return false, fmt.Errorf("boolean %q should be true or false", s)
+}
+
+// parseList parses a TOML array of strings such as ["a", "b"]. Items
+// are quoted strings, which may contain commas and escape sequences, or
+// literal strings in single quotes. A trailing comma is allowed.
+func parseList(s string) ([]string, error) {
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("list %q should be enclosed in []", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	var ret []string
+	for s != "" {
+		item, rest, err := parseString(s)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, item)
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return nil, fmt.Errorf("list items should be separated by commas, found %q", rest)
+		}
+		s = strings.TrimSpace(rest[1:])
+	}
+	return ret, nil
+}
 
-	return fm.fromMap(m)
+// parseString parses the quoted or literal string at the start of s. It
+// returns the string's value and the rest of s.
+func parseString(s string) (val, rest string, err error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++ // Skip the escaped character.
+			case '"':
+				val, err := strconv.Unquote(s[:i+1])
+				return val, s[i+1:], err
+			}
+		}
+	case strings.HasPrefix(s, "'"):
+		if i := strings.IndexByte(s[1:], '\''); i >= 0 {
+			return s[1 : i+1], s[i+2:], nil
+		}
+	}
+	return "", "", fmt.Errorf("list item %q should be quoted", s)
 }
 
-// trimFrontMatter removes front matter (if any) from the input
-// and returns the result.
+// trimFrontMatter removes front matter (if any), separated by sep as
+// in Parse, from the input and returns the result.
 //
 // The function works on []byte to facililate working with
 // blackfriday functions.
-func trimFrontMatter(b []byte) []byte {
-	if !bytes.HasPrefix(b, FrontMatterSepBytes) {
-		return b
+func trimFrontMatter(b []byte, sep string) []byte {
+	first := b
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		first = b[:i]
 	}
-	ret := b[len(FrontMatterSepBytes):]
-	idx := bytes.Index(ret, FrontMatterSepBytes)
-	if idx == -1 {
+	if !isFrontMatterSep(string(first), sep) {
 		return b
 	}
-	return bytes.TrimLeftFunc(ret[idx+len(FrontMatterSepBytes):], unicode.IsSpace)
+
+	// The closing separator is the first line that is the separator,
+	// as in Parse; a "+++" elsewhere in a line is not a separator.
+	rest := b[len(first):]
+	for len(rest) > 0 {
+		rest = rest[1:] // Skip the newline.
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i]
+		}
+		if isFrontMatterSep(string(line), sep) {
+			return bytes.TrimLeftFunc(rest[len(line):], unicode.IsSpace)
+		}
+		rest = rest[len(line):]
+	}
+	return b
 }
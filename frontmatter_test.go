@@ -2,7 +2,10 @@ package main
 
 import (
 	"bytes"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestStripFrontMatter(t *testing.T) {
@@ -23,12 +26,239 @@ title = foo
 			[]byte(`# bar`),
 			[]byte(`# bar`),
 		},
+		{
+			[]byte("+++\r\ntitle = foo\r\n+++\r\n# bar\r\nbaz"),
+			[]byte("# bar\r\nbaz"),
+		},
+		{
+			[]byte("+++\r\ntitle = foo\n+++\r\n\r\n# bar"),
+			[]byte("# bar"),
+		},
+		{
+			[]byte("++++\ntitle = foo\n+++\n# bar"),
+			[]byte("++++\ntitle = foo\n+++\n# bar"),
+		},
+		{
+			[]byte("+++\ntitle = \"C+++ and D+++\"\n+++\n# bar"),
+			[]byte("# bar"),
+		},
+		{
+			[]byte("+++\ntitle = foo\n+++\nExample:\n\n```\n+++\ntitle = \"example\"\n+++\n```\n"),
+			[]byte("Example:\n\n```\n+++\ntitle = \"example\"\n+++\n```\n"),
+		},
+		{
+			[]byte("+++\ntitle = foo\nnot closed +++"),
+			[]byte("+++\ntitle = foo\nnot closed +++"),
+		},
+		{
+			[]byte("+++\ntitle = foo\n+++"),
+			[]byte(""),
+		},
 	}
 
 	for _, tc := range testcases {
-		res := trimFrontMatter(tc.in)
+		res := trimFrontMatter(tc.in, "")
 		if !bytes.Equal(res, tc.expected) {
 			t.Fatalf("trimFrontMatter: got %s, expected %s", res, tc.expected)
 		}
 	}
 }
+
+func TestParseDescriptionTags(t *testing.T) {
+	t.Parallel()
+
+	fm := FrontMatter{}
+	in := "+++\ndescription = \"A first post\"\ntags = [\"hello\", \"world\"]\n+++\n"
+	if err := fm.Parse(strings.NewReader(in), ""); err != nil {
+		t.Fatal(err)
+	}
+	if fm.Description != "A first post" {
+		t.Fatalf("Description: got %q, expected %q", fm.Description, "A first post")
+	}
+	if !reflect.DeepEqual(fm.Tags, []string{"hello", "world"}) {
+		t.Fatalf("Tags: got %q, expected %q", fm.Tags, []string{"hello", "world"})
+	}
+
+	for _, tags := range []string{`hello`, `[hello]`, `["hello", world]`} {
+		fm := FrontMatter{}
+		if err := fm.Parse(strings.NewReader("+++\ntags = "+tags+"\n+++\n"), ""); err == nil {
+			t.Fatalf("tags = %s: expected error", tags)
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in       string
+		expected []string
+		err      bool
+	}{
+		{`["a", "b"]`, []string{"a", "b"}, false},
+		{`[]`, nil, false},
+		{`[ "a" ,"b", ]`, []string{"a", "b"}, false},
+		{`["hello, world", "c"]`, []string{"hello, world", "c"}, false},
+		{`["say \"hi\", ok"]`, []string{`say "hi", ok`}, false},
+		{`['C:\dir', "x"]`, []string{`C:\dir`, "x"}, false},
+		{`["a" "b"]`, nil, true},
+		{`[a, b]`, nil, true},
+		{`["a`, nil, true},
+		{`"a", "b"`, nil, true},
+	}
+
+	for _, tc := range testcases {
+		got, err := parseList(tc.in)
+		if (err != nil) != tc.err {
+			t.Fatalf("parseList %s: got err %v, expected error %t", tc.in, err, tc.err)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("parseList %s: got %q, expected %q", tc.in, got, tc.expected)
+		}
+	}
+}
+
+func TestFrontMatterParse(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in       string
+		expected FrontMatter
+	}{
+		{
+			"+++\ntags = [\"go, rust\", 'c']\ndraft = true\n+++\n",
+			FrontMatter{Tags: []string{"go, rust", "c"}, Draft: true},
+		},
+		{
+			"+++\ntitle = \"Hello, world\"\ndescription = 'A \"quoted\" post'\ndraft = false\n+++\n",
+			FrontMatter{Title: "Hello, world", Description: `A "quoted" post`},
+		},
+		{
+			"+++\ntitle = Unquoted\ndraft = \"true\"\n+++\n",
+			FrontMatter{Title: "Unquoted", Draft: true},
+		},
+	}
+
+	for _, tc := range testcases {
+		var got FrontMatter
+		if err := got.Parse(strings.NewReader(tc.in), ""); err != nil {
+			t.Fatalf("Parse %q: %s", tc.in, err)
+		}
+		got.keys = nil
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("Parse %q: got %+v, expected %+v", tc.in, got, tc.expected)
+		}
+	}
+
+	crlf := "+++\r\ntitle = \"Hello\"\r\ntags = [\"a\", \"b\"]\r\ndraft = true\r\n+++\r\nbody\r\n"
+	var got FrontMatter
+	if err := got.Parse(strings.NewReader(crlf), ""); err != nil {
+		t.Fatalf("Parse %q: %s", crlf, err)
+	}
+	got.keys = nil
+	if expected := (FrontMatter{Title: "Hello", Tags: []string{"a", "b"}, Draft: true}); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Parse %q: got %+v, expected %+v", crlf, got, expected)
+	}
+
+	var fm FrontMatter
+	if err := fm.Parse(strings.NewReader("+++\ndraft = yes\n+++\n"), ""); err == nil {
+		t.Fatalf("Parse draft = yes: expected error")
+	}
+}
+
+func TestFrontMatterSep(t *testing.T) {
+	t.Parallel()
+
+	in := "---\ntitle = \"Hello\"\ndraft = true\n---\n# bar\n+++\n"
+	var got FrontMatter
+	if err := got.Parse(strings.NewReader(in), "---"); err != nil {
+		t.Fatalf("Parse %q with ---: %s", in, err)
+	}
+	if got.Title != "Hello" || !got.Draft {
+		t.Fatalf("Parse %q with ---: got %+v, expected title and draft", in, got)
+	}
+	if res := trimFrontMatter([]byte(in), "---"); string(res) != "# bar\n+++\n" {
+		t.Fatalf("trimFrontMatter %q with ---: got %q", in, res)
+	}
+
+	// The default separator does not match other separators.
+	if err := got.Parse(strings.NewReader(in), ""); err != ErrNoFrontMatter {
+		t.Fatalf("Parse %q: got error %v, expected %v", in, err, ErrNoFrontMatter)
+	}
+	if res := trimFrontMatter([]byte(in), ""); string(res) != in {
+		t.Fatalf("trimFrontMatter %q: got %q, expected input unchanged", in, res)
+	}
+
+	fm := FrontMatter{Title: "Hello", Time: time.Date(2016, time.March, 4, 0, 0, 0, 0, time.UTC)}
+	s := fm.format(";;;")
+	if !strings.HasPrefix(s, ";;;\n") || !strings.HasSuffix(s, ";;;\n") {
+		t.Fatalf("format with ;;;: got %q", s)
+	}
+	if err := got.Parse(strings.NewReader(s), ";;;"); err != nil || got.Title != "Hello" {
+		t.Fatalf("Parse(format(;;;)): got %+v, error %v", got, err)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in       string
+		expected time.Time
+	}{
+		{"2016-03-04T15:30:00Z", time.Date(2016, time.March, 4, 15, 30, 0, 0, time.UTC)},
+		{"2016-03-04T15:30:00+05:30", time.Date(2016, time.March, 4, 15, 30, 0, 0, time.FixedZone("", 5*60*60+30*60))},
+		{"2016-03-04T15:30:00", time.Date(2016, time.March, 4, 15, 30, 0, 0, time.UTC)},
+		{"2016-03-04", time.Date(2016, time.March, 4, 0, 0, 0, 0, time.UTC)},
+		{"2016-03-04 15:30:00 -07:00", time.Date(2016, time.March, 4, 15, 30, 0, 0, time.FixedZone("", -7*60*60))},
+		{"1457105400", time.Date(2016, time.March, 4, 15, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testcases {
+		got, err := parseTime(tc.in)
+		if err != nil {
+			t.Fatalf("parseTime %q: %s", tc.in, err)
+		}
+		if !got.Equal(tc.expected) {
+			t.Fatalf("parseTime %q: got %s, expected %s", tc.in, got, tc.expected)
+		}
+	}
+
+	if _, err := parseTime("March 4"); err == nil {
+		t.Fatalf("parseTime %q: expected error", "March 4")
+	}
+}
+
+func TestFrontMatterStringRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	testcases := []FrontMatter{
+		{},
+		{Title: "Hello, world", Time: time.Date(2016, time.March, 4, 15, 30, 0, 0, time.UTC)},
+		{
+			Draft:       true,
+			Title:       `Say "hi" = hello`,
+			Description: "A first post",
+			Tags:        []string{"hello", "world"},
+			Time:        time.Date(2016, time.March, 4, 15, 30, 0, 0, time.FixedZone("", -7*60*60)),
+			Expiry:      time.Date(2017, time.March, 4, 0, 0, 0, 0, time.UTC),
+			Output:      "txt",
+			Layout:      "wide.tmpl",
+			Aliases:     []string{"/old/hello", "/hi"},
+			Params:      map[string]string{"image": "/img/a.png", "author": `Jane "J" Doe`},
+		},
+	}
+
+	for _, fm := range testcases {
+		var got FrontMatter
+		if err := got.Parse(strings.NewReader(fm.String()), ""); err != nil {
+			t.Fatalf("Parse %q: %s", fm.String(), err)
+		}
+		if got.Draft != fm.Draft || got.Title != fm.Title || got.Description != fm.Description ||
+			!reflect.DeepEqual(got.Tags, fm.Tags) || !got.Time.Equal(fm.Time) || !got.Expiry.Equal(fm.Expiry) ||
+			got.Output != fm.Output || got.Layout != fm.Layout || !reflect.DeepEqual(got.Aliases, fm.Aliases) ||
+			!reflect.DeepEqual(got.Params, fm.Params) {
+			t.Fatalf("Parse(String()): got %+v, expected %+v", got, fm)
+		}
+	}
+}
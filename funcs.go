@@ -1,15 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"html/template"
+	"net/http"
 	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	texttemplate "text/template"
+	"time"
 )
 
+// funcsMu guards funcs, to which RegisterFunc adds.
+var funcsMu sync.Mutex
+
+// funcs is the functions available to markdown files.
 var funcs = texttemplate.FuncMap{
 	"Gist": func(v ...interface{}) (template.HTML, error) {
+		if len(v) == 1 || len(v) == 2 {
+			if err := checkGist(v[0].(string)); err != nil {
+				return "", err
+			}
+		}
 		switch len(v) {
 		case 1:
 			return template.HTML(fmt.Sprintf("<script src=\"https://gist.github.com/%s.js\"></script>", v[0].(string))), nil
@@ -27,4 +43,259 @@ valid examples:
 {{ Gist "123abcedef" "bar.rb" }}`)
 		}
 	},
+	"Figure": figure,
+	"Tweet":  tweet,
+	"Vimeo":  vimeo,
+	"oEmbed": func(endpoint, url string) (template.HTML, error) {
+		return defaultOEmbed.Embed(endpoint, url)
+	},
+	"TOC": func() template.HTML {
+		return tocPlaceholder
+	},
+	"formatTime": formatTime,
+	"now":        time.Now,
+	"Markdown":   markdown,
+}
+
+// DefaultPluginTimeout is the default time limit for a request made by a
+// function that accesses the network, such as oEmbed.
+const DefaultPluginTimeout = 10 * time.Second
+
+// pluginClient is the HTTP client used by functions that access the
+// network. Its timeout is set by the -plugintimeout flag.
+var pluginClient = &http.Client{Timeout: DefaultPluginTimeout}
+
+// CheckGists indicates whether the Gist function verifies that the
+// gist exists, failing the build otherwise. It is set by -checkgists.
+var CheckGists bool
+
+// gistURL is the base URL of gists, replaced in tests.
+var gistURL = "https://gist.github.com"
+
+// checkGist returns an error if CheckGists is true and the gist with id,
+// such as "user/123abcdef", does not exist.
+func checkGist(id string) error {
+	if !CheckGists {
+		return nil
+	}
+	resp, err := pluginClient.Head(gistURL + "/" + id)
+	if err != nil {
+		return fmt.Errorf("Gist %q: %s", id, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Gist %q: %s responded %s", id, gistURL, resp.Status)
+	}
+	return nil
+}
+
+// DefaultFuncs returns a copy of the functions available to markdown
+// files: the built-in functions, such as Gist and Tweet, and the
+// functions added with RegisterFunc.
+func DefaultFuncs() texttemplate.FuncMap {
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+	m := make(texttemplate.FuncMap, len(funcs))
+	for k, v := range funcs {
+		m[k] = v
+	}
+	return m
+}
+
+// RegisterFunc makes fn available to markdown files as name, for
+// programs that build on batsman. fn must be a valid template function,
+// as described in text/template, and name must not already be in use.
+func RegisterFunc(name string, fn interface{}) (err error) {
+	if fn == nil || reflect.ValueOf(fn).Kind() != reflect.Func || reflect.ValueOf(fn).IsNil() {
+		return fmt.Errorf("RegisterFunc %q: fn should be a non-nil func", name)
+	}
+	defer func() {
+		// Funcs panics for an invalid name or signature.
+		if e := recover(); e != nil {
+			err = fmt.Errorf("RegisterFunc %q: %v", name, e)
+		}
+	}()
+	texttemplate.New("").Funcs(texttemplate.FuncMap{name: fn})
+
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+	if _, ok := funcs[name]; ok {
+		return fmt.Errorf("RegisterFunc %q: name already registered", name)
+	}
+	funcs[name] = fn
+	return nil
+}
+
+// markdown renders the markdown in s to HTML. The result is not escaped
+// when used in html/template templates.
+func markdown(s string) template.HTML {
+	return renderMarkdown([]byte(s), DefaultMarkdownExtensions, "")
+}
+
+// truncate returns s shortened to n runes, with the last rune replaced
+// by an ellipsis, if it is longer than n runes.
+func truncate(n int, s string) string {
+	if n <= 0 {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// timeLayouts is a map from preset names accepted by formatTime to
+// time layouts.
+var timeLayouts = map[string]string{
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04",
+	"kitchen":  time.Kitchen,
+	"long":     "January 2, 2006",
+	"short":    "Jan 2, 2006",
+	"rfc822":   time.RFC822,
+	"rfc822z":  time.RFC822Z,
+	"rfc1123":  time.RFC1123,
+	"rfc1123z": time.RFC1123Z,
+	"rfc3339":  time.RFC3339,
+}
+
+// formatTime formats t using layout, which is either a preset name in
+// timeLayouts, such as "rfc822", or a time layout, such as "Jan 2, 2006"
+// or "on Jan 2, 2006". A layout that looks like a preset name, being
+// only lowercase letters and digits, but is not one is an error, since
+// it is likely a misspelled preset.
+func formatTime(t time.Time, layout string) (string, error) {
+	if l, ok := timeLayouts[layout]; ok {
+		return t.Format(l), nil
+	}
+	if isPresetName(layout) {
+		names := make([]string, 0, len(timeLayouts))
+		for k := range timeLayouts {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("formatTime: unknown preset %q, expected a time layout or one of: %s",
+			layout, strings.Join(names, ", "))
+	}
+	return t.Format(layout), nil
+}
+
+// isPresetName returns whether s has the form of a preset name for
+// formatTime: a lowercase letter followed by lowercase letters and
+// digits.
+func isPresetName(s string) bool {
+	if s == "" || s[0] < 'a' || s[0] > 'z' {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// figure returns a figure element for an image with optional alt text
+// and caption. The alt text and caption are HTML-escaped. The image URL
+// must be relative or have the http or https scheme, so that URLs such
+// as "javascript:..." are rejected.
+func figure(v ...interface{}) (template.HTML, error) {
+	var src, alt, caption string
+	switch len(v) {
+	case 3:
+		caption = v[2].(string)
+		fallthrough
+	case 2:
+		alt = v[1].(string)
+		fallthrough
+	case 1:
+		src = v[0].(string)
+	default:
+		return "", errors.New(`Figure: invalid arguments
+valid examples:
+{{ Figure "/img/x.png" }}
+{{ Figure "/img/x.png" "alt text" }}
+{{ Figure "/img/x.png" "alt text" "A caption" }}`)
+	}
+
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("Figure: %s", err)
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("Figure: URL %q should be relative or have the http or https scheme", src)
+	}
+
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "<figure><img src=\"%s\" alt=\"%s\">",
+		template.HTMLEscapeString(src),
+		template.HTMLEscapeString(alt),
+	)
+	if caption != "" {
+		fmt.Fprintf(&buf, "<figcaption>%s</figcaption>", template.HTMLEscapeString(caption))
+	}
+	buf.WriteString("</figure>")
+	return template.HTML(buf.String()), nil
+}
+
+// tweet returns the embed markup for a tweet.
+//
+// The widgets.js script is included with every tweet. The script is
+// idempotent, so multiple tweets on a page are fine.
+func tweet(v ...interface{}) (template.HTML, error) {
+	var user, id string
+	switch len(v) {
+	case 1:
+		u, err := url.Parse(v[0].(string))
+		if err != nil {
+			return "", fmt.Errorf("Tweet: %s", err)
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) != 3 || parts[1] != "status" {
+			return "", fmt.Errorf("Tweet: URL %q should be in format \"https://twitter.com/user/status/123\"", v[0])
+		}
+		user, id = parts[0], parts[2]
+	case 2:
+		user, id = v[0].(string), v[1].(string)
+	default:
+		return "", errors.New(`Tweet: invalid arguments
+valid examples:
+{{ Tweet "https://twitter.com/user/status/123" }}
+{{ Tweet "user" "123" }}`)
+	}
+
+	if !isDigits(id) {
+		return "", fmt.Errorf("Tweet: status ID %q should be numeric", id)
+	}
+	return template.HTML(fmt.Sprintf(
+		"<blockquote class=\"twitter-tweet\"><a href=\"https://twitter.com/%s/status/%s\"></a></blockquote>"+
+			"<script async src=\"https://platform.twitter.com/widgets.js\" charset=\"utf-8\"></script>",
+		url.PathEscape(user), id,
+	)), nil
+}
+
+// vimeo returns the embed markup for the Vimeo video with the numeric id.
+func vimeo(id string) (template.HTML, error) {
+	if !isDigits(id) {
+		return "", fmt.Errorf("Vimeo: video ID %q should be numeric, as in {{ Vimeo \"76979871\" }}", id)
+	}
+	return template.HTML(fmt.Sprintf(
+		"<iframe src=\"https://player.vimeo.com/video/%s\" width=\"640\" height=\"360\" frameborder=\"0\" "+
+			"allow=\"autoplay; fullscreen; picture-in-picture\" allowfullscreen></iframe>",
+		id,
+	)), nil
+}
+
+// isDigits returns whether s is non-empty and consists only of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
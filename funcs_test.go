@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFigure(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in       []interface{}
+		expected template.HTML
+	}{
+		{
+			[]interface{}{"/img/x.png"},
+			`<figure><img src="/img/x.png" alt=""></figure>`,
+		},
+		{
+			[]interface{}{"/img/x.png", "alt text"},
+			`<figure><img src="/img/x.png" alt="alt text"></figure>`,
+		},
+		{
+			[]interface{}{"/img/x.png", "alt text", "A caption"},
+			`<figure><img src="/img/x.png" alt="alt text"><figcaption>A caption</figcaption></figure>`,
+		},
+		{
+			[]interface{}{"/img/x.png?a=1&b=2", `"quoted"`, "1 < 2 & 3"},
+			`<figure><img src="/img/x.png?a=1&amp;b=2" alt="&#34;quoted&#34;"><figcaption>1 &lt; 2 &amp; 3</figcaption></figure>`,
+		},
+	}
+
+	for _, tc := range testcases {
+		res, err := figure(tc.in...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != tc.expected {
+			t.Fatalf("figure: got %s, expected %s", res, tc.expected)
+		}
+	}
+
+	if _, err := figure(); err == nil {
+		t.Fatal("figure: expected error for no arguments")
+	}
+	for _, src := range []string{"javascript:alert(1)", "JavaScript:alert(1)", "data:text/html,<script>alert(1)</script>", " javascript:alert(1)"} {
+		if _, err := figure(src); err == nil {
+			t.Fatalf("figure %q: expected error for unsafe URL", src)
+		}
+	}
+	if _, err := figure("https://example.com/x.png"); err != nil {
+		t.Fatalf("figure: got %v, expected no error for https URL", err)
+	}
+	if _, err := figure("a", "b", "c", "d"); err == nil {
+		t.Fatal("figure: expected error for too many arguments")
+	}
+}
+
+func TestTweet(t *testing.T) {
+	t.Parallel()
+
+	const expected = `<blockquote class="twitter-tweet"><a href="https://twitter.com/user/status/123"></a></blockquote>` +
+		`<script async src="https://platform.twitter.com/widgets.js" charset="utf-8"></script>`
+
+	testcases := [][]interface{}{
+		{"https://twitter.com/user/status/123"},
+		{"https://x.com/user/status/123/"},
+		{"user", "123"},
+	}
+
+	for _, tc := range testcases {
+		res, err := tweet(tc...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != expected {
+			t.Fatalf("tweet: got %s, expected %s", res, expected)
+		}
+	}
+
+	invalid := [][]interface{}{
+		{"user", "12a"},
+		{"user", ""},
+		{"https://twitter.com/user/status/abc"},
+		{"https://twitter.com/user"},
+		{},
+	}
+
+	for _, tc := range invalid {
+		if _, err := tweet(tc...); err == nil {
+			t.Fatalf("tweet(%q): expected error", tc)
+		}
+	}
+}
+
+func TestVimeo(t *testing.T) {
+	t.Parallel()
+
+	res, err := vimeo("76979871")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(res), `<iframe src="https://player.vimeo.com/video/76979871" `) {
+		t.Fatalf("vimeo: got %s", res)
+	}
+
+	for _, id := range []string{"", "abc", "123?autoplay=1", "https://vimeo.com/76979871"} {
+		if _, err := vimeo(id); err == nil {
+			t.Fatalf("vimeo(%q): expected error", id)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	t.Parallel()
+
+	tm := time.Date(2016, time.March, 4, 15, 30, 0, 0, time.UTC)
+	testcases := []struct {
+		layout   string
+		expected string
+		err      bool
+	}{
+		{"Jan 2, 2006", "Mar 4, 2016", false},
+		{"2006-01-02", "2016-03-04", false},
+		{"rfc822", "04 Mar 16 15:30 UTC", false},
+		{"date", "2016-03-04", false},
+		{"", "", false},
+		{"on Jan 2, 2006", "on Mar 4, 2016", false},
+		{"pm 3:04", "pm 3:30", false},
+		{"rfc9999", "", true},
+	}
+
+	for _, tc := range testcases {
+		got, err := formatTime(tm, tc.layout)
+		if (err != nil) != tc.err {
+			t.Fatalf("formatTime %q: got err %v, expected error %t", tc.layout, err, tc.err)
+		}
+		if got != tc.expected {
+			t.Fatalf("formatTime %q: got %s, expected %s", tc.layout, got, tc.expected)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	t.Parallel()
+
+	const expected = "<p><strong>bold</strong>, <em>italic</em> &amp; more</p>\n"
+	if got := markdown("**bold**, *italic* & more"); got != expected {
+		t.Fatalf("markdown: got %s, expected %s", got, expected)
+	}
+
+	tmpl := template.Must(template.New("").Funcs(template.FuncMap{"Markdown": markdown}).Parse(`<div>{{ Markdown .}}</div>`))
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, "**bold**, *italic* & more"); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "<div>"+expected+"</div>" {
+		t.Fatalf("Markdown in template: got %s, expected %s", got, "<div>"+expected+"</div>")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		n        int
+		s        string
+		expected string
+	}{
+		{5, "hello", "hello"},
+		{4, "hello", "hel…"},
+		{3, "héllo wörld", "hé…"},
+		{2, "日本語", "日…"},
+		{3, "日本語", "日本語"},
+		{1, "日本語", "…"},
+		{0, "hello", ""},
+		{5, "", ""},
+	}
+
+	for _, tc := range testcases {
+		if got := truncate(tc.n, tc.s); got != tc.expected {
+			t.Fatalf("truncate %d %q: got %s, expected %s", tc.n, tc.s, got, tc.expected)
+		}
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	if err := RegisterFunc("testShout", strings.ToUpper); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name string
+		fn   interface{}
+	}{
+		{"testShout", strings.ToLower},    // Already registered.
+		{"Gist", strings.ToLower},         // Built in.
+		{"testNil", nil},                  // Nil.
+		{"testNilFunc", (func())(nil)},    // Nil func.
+		{"testNotFunc", "x"},              // Not a func.
+		{"test-invalid", strings.ToLower}, // Invalid name.
+		{"testNoResult", func() {}},       // Invalid signature.
+	}
+	for _, tc := range testcases {
+		if err := RegisterFunc(tc.name, tc.fn); err == nil {
+			t.Fatalf("RegisterFunc %q: expected error", tc.name)
+		}
+	}
+	if _, ok := DefaultFuncs()["testNil"]; ok {
+		t.Fatalf("DefaultFuncs: unexpected testNil")
+	}
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `{{ .Current.Content }}`,
+		"src/page.md":     `{{ testShout "hello" }}`,
+	})
+	t.Chdir(dir)
+	if err := (&Build{NoMinify: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := readFile(t, "build/page/index.html"), "<p>HELLO</p>\n"; got != expected {
+		t.Fatalf("build/page/index.html: got %q, expected %q", got, expected)
+	}
+}
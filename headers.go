@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// headerFlag is a flag.Value for the repeatable -header flag. Each value
+// is a "Name: Value" header.
+type headerFlag []string
+
+func (f *headerFlag) String() string { return strings.Join(*f, ", ") }
+
+func (f *headerFlag) Set(s string) error {
+	if _, _, err := parseHeader(s); err != nil {
+		return err
+	}
+	*f = append(*f, s)
+	return nil
+}
+
+func (f *headerFlag) Get() interface{} { return []string(*f) }
+
+// parseHeader parses a "Name: Value" header.
+func parseHeader(s string) (name, value string, err error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return "", "", fmt.Errorf("header %q should be in format \"Name: Value\"", s)
+	}
+	name, value = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if name == "" || strings.ContainsAny(name, " \t\r\n") || strings.ContainsAny(value, "\r\n") {
+		return "", "", fmt.Errorf("header %q should be in format \"Name: Value\"", s)
+	}
+	return name, value, nil
+}
+
+// parseHeaders parses "Name: Value" headers. Headers with the same name
+// are all kept.
+func parseHeaders(list []string) (http.Header, error) {
+	h := make(http.Header, len(list))
+	for _, s := range list {
+		name, value, err := parseHeader(s)
+		if err != nil {
+			return nil, err
+		}
+		h.Add(name, value)
+	}
+	return h, nil
+}
+
+// withHeaders returns a handler that adds headers to each response
+// before serving it with h.
+func withHeaders(headers http.Header, h http.Handler) http.Handler {
+	if len(headers) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range headers {
+			for _, v := range values {
+				w.Header().Add(name, v)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// cacheControl returns a handler that serves requests with h, setting
+// the Cache-Control header of HTML responses to "no-cache", so that
+// edits show up, and of other responses to value. A Cache-Control
+// header already set, such as with -header, is left as it is.
+func cacheControl(value string, h http.Handler) http.Handler {
+	if value == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(&cacheControlWriter{ResponseWriter: w, r: r, value: value}, r)
+	})
+}
+
+// cacheControlWriter sets the Cache-Control header, based on the
+// response's content type, before the header is written.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	r     *http.Request
+	value string
+	done  bool
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	w.setHeader()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheControlWriter) Write(p []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *cacheControlWriter) setHeader() {
+	if w.done {
+		return
+	}
+	w.done = true
+	h := w.Header()
+	if h.Get("Cache-Control") != "" {
+		return
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		// Such as for a 304 response; use the requested path instead.
+		ext := path.Ext(w.r.URL.Path)
+		ct = mime.TypeByExtension(ext)
+		if ext == "" {
+			ct = "text/html"
+		}
+	}
+	if strings.HasPrefix(ct, "text/html") {
+		h.Set("Cache-Control", "no-cache")
+	} else {
+		h.Set("Cache-Control", w.value)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in       []string
+		expected http.Header
+		err      bool
+	}{
+		{nil, http.Header{}, false},
+		{
+			[]string{"Cache-Control: no-store", "x-a:1", "X-A: 2", "Content-Security-Policy: default-src 'self'; img-src *"},
+			http.Header{
+				"Cache-Control":           {"no-store"},
+				"X-A":                     {"1", "2"},
+				"Content-Security-Policy": {"default-src 'self'; img-src *"},
+			},
+			false,
+		},
+		{[]string{"X-Empty:"}, http.Header{"X-Empty": {""}}, false},
+		{[]string{"no colon"}, nil, true},
+		{[]string{": value"}, nil, true},
+		{[]string{"Bad Name: value"}, nil, true},
+	}
+
+	for _, tc := range testcases {
+		got, err := parseHeaders(tc.in)
+		if (err != nil) != tc.err {
+			t.Fatalf("parseHeaders(%q): got error %v, expected error %t", tc.in, err, tc.err)
+		}
+		if !tc.err && !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("parseHeaders(%q): got %v, expected %v", tc.in, got, tc.expected)
+		}
+	}
+}
+
+func TestHeaderFlag(t *testing.T) {
+	t.Parallel()
+
+	var h headerFlag
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&h, "header", "")
+	if err := fs.Parse([]string{"-header", "X-A: 1", "-header", "X-B: 2"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (headerFlag{"X-A: 1", "X-B: 2"}); !reflect.DeepEqual(h, expected) {
+		t.Fatalf("-header: got %q, expected %q", h, expected)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Var(&h, "header", "")
+	if err := fs.Parse([]string{"-header", "malformed"}); err == nil {
+		t.Fatalf("-header malformed: expected error")
+	}
+}
+
+func TestWithHeaders(t *testing.T) {
+	t.Parallel()
+
+	headers := http.Header{"Cache-Control": {"no-store"}, "X-A": {"1", "2"}}
+	h := withHeaders(headers, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	}))
+
+	for _, path := range []string{"/", "/missing"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+			t.Fatalf("GET %s: got Cache-Control %q, expected %q", path, got, "no-store")
+		}
+		if got := rec.Header()["X-A"]; !reflect.DeepEqual(got, []string{"1", "2"}) {
+			t.Fatalf("GET %s: got X-A %q, expected %q", path, got, []string{"1", "2"})
+		}
+		if got := rec.Header().Get("Content-Type"); got != "text/plain" {
+			t.Fatalf("GET %s: got Content-Type %q, expected %q", path, got, "text/plain")
+		}
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"index.html": "<p>home</p>",
+		"style.css":  "p{}",
+	})
+	h := cacheControl("max-age=3600", http.FileServer(http.Dir(dir)))
+
+	testcases := []struct {
+		path     string
+		header   string // Cache-Control set before serving, as with -header.
+		expected string
+	}{
+		{"/", "", "no-cache"},
+		{"/index.html", "", "no-cache"},
+		{"/style.css", "", "max-age=3600"},
+		{"/style.css", "no-store", "no-store"},
+	}
+
+	for _, tc := range testcases {
+		rec := httptest.NewRecorder()
+		if tc.header != "" {
+			rec.Header().Set("Cache-Control", tc.header)
+		}
+		h.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+		if got := rec.Header().Get("Cache-Control"); got != tc.expected {
+			t.Fatalf("GET %s: got Cache-Control %q, expected %q", tc.path, got, tc.expected)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	cacheControl("", http.FileServer(http.Dir(dir))).ServeHTTP(rec, httptest.NewRequest("GET", "/style.css", nil))
+	if got := rec.Header().Get("Cache-Control"); got != "" {
+		t.Fatalf("GET /style.css: got Cache-Control %q, expected none", got)
+	}
+}
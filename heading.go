@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// headingRe matches h1-h6 elements. The submatches are the level,
+// the attributes, and the inner HTML.
+var headingRe = regexp.MustCompile(`(?s)<h([1-6])((?:\s[^>]*)?)>(.*?)</h[1-6]>`)
+
+// idAttrRe matches an id attribute. The submatch is the value.
+var idAttrRe = regexp.MustCompile(`\s+id="([^"]*)"`)
+
+// slugifyHeading returns the text lowercased, with spaces replaced by
+// hyphens and punctuation removed.
+func slugifyHeading(text string) string {
+	buf := bytes.Buffer{}
+	hyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			if hyphen && buf.Len() > 0 {
+				buf.WriteByte('-')
+			}
+			hyphen = false
+			buf.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-':
+			hyphen = true
+		}
+	}
+	return buf.String()
+}
+
+// slugger returns unique slugs for headings in a page.
+// The zero value is ready to use.
+type slugger struct {
+	used map[string]bool
+}
+
+// slug returns slugifyHeading(text), suffixed with "-1", "-2", and so on
+// if the slug has already been returned.
+func (s *slugger) slug(text string) string {
+	if s.used == nil {
+		s.used = make(map[string]bool)
+	}
+	base := slugifyHeading(text)
+	slug := base
+	for n := 1; s.used[slug]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	s.used[slug] = true
+	return slug
+}
+
+// addHeadingIDs sets the id of each h1-h6 heading in content that has
+// none to a slug of its text that is unique within content. Existing
+// ids, such as those written by the author, are kept so that links to
+// them still work.
+func addHeadingIDs(content template.HTML) template.HTML {
+	sl := slugger{used: make(map[string]bool)}
+	for _, sub := range headingRe.FindAllStringSubmatch(string(content), -1) {
+		if id := idAttrRe.FindStringSubmatch(sub[2]); id != nil {
+			sl.used[id[1]] = true
+		}
+	}
+	return template.HTML(headingRe.ReplaceAllStringFunc(string(content), func(m string) string {
+		sub := headingRe.FindStringSubmatch(m)
+		if idAttrRe.MatchString(sub[2]) {
+			return m
+		}
+		level, attrs, inner := sub[1], sub[2], sub[3]
+		id := sl.slug(plainText(template.HTML(inner)))
+		return fmt.Sprintf("<h%s id=\"%s\"%s>%s</h%s>", level, id, attrs, inner, level)
+	}))
+}
+
+// addHeadingAnchors appends a "#" link to itself to each h2-h6 heading
+// in content that has an id.
+func addHeadingAnchors(content template.HTML) template.HTML {
+	return template.HTML(headingRe.ReplaceAllStringFunc(string(content), func(m string) string {
+		sub := headingRe.FindStringSubmatch(m)
+		id := idAttrRe.FindStringSubmatch(sub[2])
+		if sub[1] == "1" || id == nil {
+			return m
+		}
+		return fmt.Sprintf("<h%s%s>%s <a class=\"anchor\" href=\"#%s\">#</a></h%s>", sub[1], sub[2], sub[3], id[1], sub[1])
+	}))
+}
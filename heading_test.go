@@ -0,0 +1,83 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestSlugifyHeading(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in, expected string
+	}{
+		{"Hello", "hello"},
+		{"Quick start", "quick-start"},
+		{"  What's new?  ", "whats-new"},
+		{"Go 1.7 - release notes", "go-17-release-notes"},
+		{"snake_case", "snake_case"},
+		{"Ünïcode Héading", "ünïcode-héading"},
+	}
+
+	for _, tc := range testcases {
+		if res := slugifyHeading(tc.in); res != tc.expected {
+			t.Fatalf("slugifyHeading(%q): got %q, expected %q", tc.in, res, tc.expected)
+		}
+	}
+}
+
+func TestSlugger(t *testing.T) {
+	t.Parallel()
+
+	sl := slugger{}
+	in := []string{"Usage", "Usage", "Usage", "Usage 1", "Other"}
+	expected := []string{"usage", "usage-1", "usage-2", "usage-1-1", "other"}
+	for i := range in {
+		if res := sl.slug(in[i]); res != expected[i] {
+			t.Fatalf("slug(%q): got %q, expected %q", in[i], res, expected[i])
+		}
+	}
+}
+
+func TestAddHeadingIDs(t *testing.T) {
+	t.Parallel()
+
+	in := template.HTML(`<h1>Title</h1>
+<h2>Setup</h2>
+<h3 class="x">Install <code>batsman</code></h3>
+<h2 id="old">Setup</h2>
+<h6>Setup</h6>
+<h3 id="setup-1">Custom</h3>`)
+	expected := template.HTML(`<h1 id="title">Title</h1>
+<h2 id="setup">Setup</h2>
+<h3 id="install-batsman" class="x">Install <code>batsman</code></h3>
+<h2 id="old">Setup</h2>
+<h6 id="setup-2">Setup</h6>
+<h3 id="setup-1">Custom</h3>`)
+
+	if res := addHeadingIDs(in); res != expected {
+		t.Fatalf("addHeadingIDs: got %s, expected %s", res, expected)
+	}
+
+	// Markdown h1 headings get ids, as they did with blackfriday's
+	// EXTENSION_AUTO_HEADER_IDS.
+	md := addHeadingIDs(renderMarkdown([]byte("# Title\n\n## Setup\n"), DefaultMarkdownExtensions, ""))
+	if expected := template.HTML("<h1 id=\"title\">Title</h1>\n\n<h2 id=\"setup\">Setup</h2>\n"); md != expected {
+		t.Fatalf("addHeadingIDs markdown: got %q, expected %q", md, expected)
+	}
+}
+
+func TestAddHeadingAnchors(t *testing.T) {
+	t.Parallel()
+
+	in := template.HTML(`<h1 id="title">Title</h1>
+<h2 id="setup">Setup <em>now</em></h2>
+<h3>No id</h3>`)
+	expected := template.HTML(`<h1 id="title">Title</h1>
+<h2 id="setup">Setup <em>now</em> <a class="anchor" href="#setup">#</a></h2>
+<h3>No id</h3>`)
+
+	if res := addHeadingAnchors(in); res != expected {
+		t.Fatalf("addHeadingAnchors: got %s, expected %s", res, expected)
+	}
+}
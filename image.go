@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+)
+
+// DefaultImageQuality is the JPEG quality used when optimizing images
+// if Build.ImageQuality is zero.
+const DefaultImageQuality = 85
+
+// imageExts is a map from the file extensions of images that can be
+// optimized to their image format.
+var imageExts = map[string]string{
+	".png":  "png",
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+}
+
+// optimizeImage re-encodes the image at src, which drops metadata, and
+// writes it to dst. quality is the JPEG quality. If src cannot be
+// decoded, or the re-encoded image is not smaller, src is copied
+// unchanged.
+func optimizeImage(dst, src string, quality int) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	m, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		_, err := writeIfChanged(dst, data)
+		return err
+	}
+
+	buf := bytes.Buffer{}
+	switch format {
+	case "png":
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		err = enc.Encode(&buf, m)
+	case "jpeg":
+		err = jpeg.Encode(&buf, m, &jpeg.Options{Quality: quality})
+	default:
+		// Contents do not match the extension.
+		_, err := writeIfChanged(dst, data)
+		return err
+	}
+	if err != nil || buf.Len() >= len(data) {
+		_, err := writeIfChanged(dst, data)
+		return err
+	}
+	_, err = writeIfChanged(dst, buf.Bytes())
+	return err
+}
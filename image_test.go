@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptimizeImage(t *testing.T) {
+	t.Parallel()
+
+	m := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			m.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 128, 255})
+		}
+	}
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, m, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"photo.jpg": buf.String(),
+		"fake.jpg":  "not an image",
+	})
+
+	testcases := []struct {
+		name   string
+		decode bool // Whether the output should decode as an image.
+	}{
+		{"photo.jpg", true},
+		{"fake.jpg", false},
+	}
+
+	for _, tc := range testcases {
+		src := filepath.Join(dir, tc.name)
+		dst := filepath.Join(dir, "out", tc.name)
+		if err := optimizeImage(dst, src, DefaultImageQuality); err != nil {
+			t.Fatalf("optimizeImage %s: %s", tc.name, err)
+		}
+		in, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) > len(in) {
+			t.Fatalf("optimizeImage %s: got %d bytes, expected at most %d", tc.name, len(out), len(in))
+		}
+		if !tc.decode {
+			if !bytes.Equal(out, in) {
+				t.Fatalf("optimizeImage %s: got %q, expected copy %q", tc.name, out, in)
+			}
+			continue
+		}
+		if _, _, err := image.Decode(bytes.NewReader(out)); err != nil {
+			t.Fatalf("optimizeImage %s: output does not decode: %s", tc.name, err)
+		}
+	}
+}
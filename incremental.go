@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// snapshot returns the modification times of the files in root, keyed
+// by path.
+func snapshot(root string) (map[string]time.Time, error) {
+	m := make(map[string]time.Time)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			m[p] = info.ModTime()
+		}
+		return nil
+	})
+	return m, err
+}
+
+// Rebuild regenerates the output for the source files changed since the
+// last run, such as after an edit in "serve -watch". The changed files
+// are regenerated along with, for a markdown file, the markdown and
+// .html files in the same directory, since their listings and links
+// depend on it. Site-wide files, such as the feed, are always written.
+// Other outputs are left as they are, even if they list the changed
+// page through All or Recent.
+//
+// A full Run is done instead if there was no previous run, if files were
+// removed, if a layout, partial, default template, directory config,
+// data file, or SCSS partial changed, or if fingerprinting is on,
+// because those affect the output of unchanged files. The config file is
+// outside the source directory; "serve -watch" reloads it and does a full
+// Run when it changes.
+func (b *Build) Rebuild() error {
+	if b.stamps == nil || b.Fingerprint {
+		return b.Run()
+	}
+
+	src := b.srcDir()
+	stamps, err := snapshot(src)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for p := range b.stamps {
+		if _, ok := stamps[p]; !ok {
+			return b.Run()
+		}
+	}
+	for p, t := range stamps {
+		if old, ok := b.stamps[p]; ok && old.Equal(t) {
+			continue
+		}
+		if filepath.Ext(p) == ".tmpl" || filepath.Base(p) == DirConfigFile || filepath.Dir(p) == filepath.Join(src, PartialsDir) || filepath.Dir(p) == filepath.Join(src, DefaultsDir) ||
+			filepath.Dir(p) == filepath.Join(src, DataDir) ||
+			scssExts[filepath.Ext(p)] && strings.HasPrefix(filepath.Base(p), "_") {
+			return b.Run()
+		}
+		changed = append(changed, p)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	only := make(map[string]bool)
+	for _, p := range changed {
+		only[p] = true
+		if !MarkdownExts[filepath.Ext(p)] {
+			continue
+		}
+		for q := range stamps {
+			if filepath.Dir(q) == filepath.Dir(p) && (MarkdownExts[filepath.Ext(q)] || filepath.Ext(q) == ".html") {
+				only[q] = true
+			}
+		}
+	}
+
+	b.only = only
+	defer func() { b.only = nil }()
+	return b.Run()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildRebuild(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":      `{{ .Current.Title }}`,
+		"src/index.html":       `home`,
+		"src/style.css":        `body{}`,
+		"src/blog/index.html":  `{{ range .Dir }}{{ .Title }} {{ end }}`,
+		"src/blog/a.md":        "+++\ntitle = \"a\"\ntime = \"2016-01-01\"\n+++\n",
+		"src/blog/b.md":        "+++\ntitle = \"b\"\ntime = \"2016-01-02\"\n+++\n",
+		"src/notes/c.md":       "+++\ntitle = \"c\"\n+++\n",
+		"src/notes/index.html": `notes`,
+	})
+	t.Chdir(dir)
+
+	all := []string{
+		"build/index.html",
+		"build/style.css",
+		"build/blog/index.html",
+		"build/blog/a/index.html",
+		"build/blog/b/index.html",
+		"build/notes/c/index.html",
+		"build/notes/index.html",
+	}
+	b := &Build{NoMinify: true}
+	// rebuild removes the build directory, applies the edits to the
+	// source, rebuilds, and checks that only the expected files are
+	// written.
+	rebuild := func(edits map[string]string, expected []string) {
+		t.Helper()
+		if err := os.RemoveAll("build"); err != nil {
+			t.Fatal(err)
+		}
+		writeTree(t, dir, edits)
+		later := time.Now().Add(time.Hour)
+		for name := range edits {
+			if err := os.Chtimes(filepath.FromSlash(name), later, later); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := b.Rebuild(); err != nil {
+			t.Fatal(err)
+		}
+		want := make(map[string]bool)
+		for _, name := range expected {
+			want[name] = true
+		}
+		for _, name := range all {
+			_, err := os.Stat(filepath.FromSlash(name))
+			if got := err == nil; got != want[name] {
+				t.Fatalf("%s: got exists %t, expected %t", name, got, want[name])
+			}
+		}
+	}
+
+	rebuild(nil, all) // No previous run.
+	rebuild(nil, nil) // Nothing changed.
+	rebuild(map[string]string{"src/blog/a.md": "+++\ntitle = \"A\"\ntime = \"2016-01-01\"\n+++\n"},
+		[]string{"build/blog/index.html", "build/blog/a/index.html", "build/blog/b/index.html"})
+	if got, expected := readFile(t, "build/blog/index.html"), "b A "; got != expected {
+		t.Fatalf("build/blog/index.html: got %q, expected %q", got, expected)
+	}
+	rebuild(map[string]string{"src/style.css": "p{}"}, []string{"build/style.css"})
+	rebuild(map[string]string{"src/notes/d.md": "+++\ntitle = \"d\"\n+++\n"},
+		[]string{"build/notes/c/index.html", "build/notes/index.html"})
+	rebuild(map[string]string{"src/layout.tmpl": `<b>{{ .Current.Title }}</b>`}, all)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitializeThemes(t *testing.T) {
+	seen := make(map[string]string) // build/index.html to theme.
+
+	for name, files := range themes {
+		dir := filepath.Join(t.TempDir(), "site")
+		if err := (&Initialize{Path: dir, Theme: name}).Run(); err != nil {
+			t.Fatalf("theme %s: %s", name, err)
+		}
+		for k, v := range files {
+			if got := readFile(t, filepath.ToSlash(filepath.Join(dir, k))); got != string(v) {
+				t.Fatalf("theme %s: %s: contents differ", name, k)
+			}
+		}
+
+		t.Chdir(dir)
+		if err := (&Build{}).Run(); err != nil {
+			t.Fatalf("theme %s: build: %s", name, err)
+		}
+		index := readFile(t, "build/index.html")
+		if other, ok := seen[index]; ok {
+			t.Fatalf("theme %s: same build/index.html as theme %s", name, other)
+		}
+		seen[index] = name
+	}
+}
+
+func TestInitializeBlogIndex(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "site")
+	if err := (&Initialize{Path: dir, Theme: "blog"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	if err := (&Build{NoMinify: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	index := readFile(t, "build/blog/index.html")
+	for _, s := range []string{
+		`<a href="/blog/second-post">Second post</a>`,
+		`<a href="/blog/hello-world">Hello, world</a>`,
+		"January 2, 2016",
+	} {
+		if !strings.Contains(index, s) {
+			t.Fatalf("build/blog/index.html: got %q, expected it to contain %q", index, s)
+		}
+	}
+}
+
+func TestInitializeUnknownTheme(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "site")
+	err := (&Initialize{Path: dir, Theme: "nope"}).Run()
+	if err == nil || !strings.Contains(err.Error(), "blog, docs, minimal") {
+		t.Fatalf("Initialize: got %v, expected error listing themes", err)
+	}
+	if exists, _ := pathExists(dir); exists {
+		t.Fatalf("Initialize: expected %s not to be created", dir)
+	}
+}
+
+func TestInitializeNonEmpty(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	existing := map[string]string{
+		"src/layout.tmpl": "mine",
+		"a.txt":           "",
+		"b.txt":           "",
+		"c.txt":           "",
+		"d.txt":           "",
+		"e.txt":           "",
+	}
+	writeTree(t, dir, existing)
+
+	err := (&Initialize{Path: dir, Theme: "minimal"}).Run()
+	if err == nil {
+		t.Fatal("Initialize: expected error for non-empty path")
+	}
+	if expected := "(contains a.txt, b.txt, c.txt, d.txt, e.txt, and 1 more)"; !strings.Contains(err.Error(), expected) {
+		t.Fatalf("Initialize: got %q, expected to contain %q", err, expected)
+	}
+	if exists, _ := pathExists(filepath.Join(dir, "src", "index.html")); exists {
+		t.Fatal("Initialize: expected no files to be written")
+	}
+
+	if err := (&Initialize{Path: dir, Theme: "minimal", Force: true}).Run(); err != nil {
+		t.Fatalf("Initialize with Force: %s", err)
+	}
+	if got := readFile(t, filepath.ToSlash(filepath.Join(dir, "src", "layout.tmpl"))); got != "mine" {
+		t.Fatalf("src/layout.tmpl: got %q, expected existing file to be kept", got)
+	}
+	for k, v := range minimalFiles {
+		if k == "src/layout.tmpl" {
+			continue
+		}
+		if got := readFile(t, filepath.ToSlash(filepath.Join(dir, k))); got != string(v) {
+			t.Fatalf("%s: got %q, expected %q", k, got, v)
+		}
+	}
+	for k := range existing {
+		if exists, _ := pathExists(filepath.Join(dir, k)); !exists {
+			t.Fatalf("%s: expected existing file to be kept", k)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"time"
+)
+
+// jsonLDArticle is a schema.org Article, as written by jsonLD.
+type jsonLDArticle struct {
+	Context       string        `json:"@context"`
+	Type          string        `json:"@type"`
+	Headline      string        `json:"headline"`
+	Description   string        `json:"description,omitempty"`
+	DatePublished string        `json:"datePublished,omitempty"`
+	Author        *jsonLDAuthor `json:"author,omitempty"`
+	URL           string        `json:"url"`
+}
+
+// jsonLDAuthor is the author of a jsonLDArticle, a schema.org Person or
+// Organization.
+type jsonLDAuthor struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+// jsonLD returns a script element with schema.org Article structured data
+// for p, such as {{ jsonLD .Current .BaseURL }}. The author is the
+// "author" front matter value, or else siteAuthor, or else the site as
+// an Organization named siteTitle. Empty description, time, and author
+// are omitted.
+func jsonLD(p *Page, baseURL, siteAuthor, siteTitle string) (template.HTML, error) {
+	a := jsonLDArticle{
+		Context:     "https://schema.org",
+		Type:        "Article",
+		Headline:    p.Title,
+		Description: p.Description,
+		URL:         joinURL(baseURL, p.Path),
+	}
+	switch {
+	case p.Params["author"] != "":
+		a.Author = &jsonLDAuthor{"Person", p.Params["author"]}
+	case siteAuthor != "":
+		a.Author = &jsonLDAuthor{"Person", siteAuthor}
+	case siteTitle != "":
+		a.Author = &jsonLDAuthor{"Organization", siteTitle}
+	}
+	if !p.Time.IsZero() {
+		a.DatePublished = p.Time.Format(time.RFC3339)
+	}
+	// json.Marshal escapes <, >, and &, so the output cannot end the
+	// script element.
+	b, err := json.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(`<script type="application/ld+json">` + string(b) + `</script>`), nil
+}
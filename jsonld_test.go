@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLD(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		page                  *Page
+		baseURL               string
+		siteAuthor, siteTitle string
+		expected              map[string]interface{}
+	}{
+		{
+			&Page{
+				Title:       "</script><b>Hi</b>",
+				Description: "A & B",
+				Path:        "/blog/hi",
+				Time:        time.Date(2016, time.March, 4, 15, 30, 0, 0, time.UTC),
+				Params:      map[string]string{"author": "Ann"},
+			},
+			"https://example.com/",
+			"Jane Doe", "Site",
+			map[string]interface{}{
+				"@context":      "https://schema.org",
+				"@type":         "Article",
+				"headline":      "</script><b>Hi</b>",
+				"description":   "A & B",
+				"datePublished": "2016-03-04T15:30:00Z",
+				"author":        map[string]interface{}{"@type": "Person", "name": "Ann"},
+				"url":           "https://example.com/blog/hi",
+			},
+		},
+		{
+			&Page{Title: "Site author", Path: "/a"},
+			"",
+			"Jane Doe", "Site",
+			map[string]interface{}{
+				"@context": "https://schema.org",
+				"@type":    "Article",
+				"headline": "Site author",
+				"author":   map[string]interface{}{"@type": "Person", "name": "Jane Doe"},
+				"url":      "/a",
+			},
+		},
+		{
+			&Page{Title: "Site title", Path: "/b"},
+			"",
+			"", "Site",
+			map[string]interface{}{
+				"@context": "https://schema.org",
+				"@type":    "Article",
+				"headline": "Site title",
+				"author":   map[string]interface{}{"@type": "Organization", "name": "Site"},
+				"url":      "/b",
+			},
+		},
+		{
+			&Page{Title: "Untimed", Path: "/untimed"},
+			"",
+			"", "",
+			map[string]interface{}{
+				"@context": "https://schema.org",
+				"@type":    "Article",
+				"headline": "Untimed",
+				"url":      "/untimed",
+			},
+		},
+	}
+
+	const prefix, suffix = `<script type="application/ld+json">`, `</script>`
+	for _, tc := range testcases {
+		res, err := jsonLD(tc.page, tc.baseURL, tc.siteAuthor, tc.siteTitle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(res)
+		if !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, suffix) {
+			t.Fatalf("jsonLD %s: got %s, expected script element", tc.page.Title, s)
+		}
+		data := s[len(prefix) : len(s)-len(suffix)]
+		if strings.ContainsAny(data, "<>&") {
+			t.Fatalf("jsonLD %s: got unescaped HTML in %s", tc.page.Title, data)
+		}
+
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &got); err != nil {
+			t.Fatalf("jsonLD %s: %s", tc.page.Title, err)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("jsonLD %s: got %v, expected %v", tc.page.Title, got, tc.expected)
+		}
+	}
+}
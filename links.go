@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	stdhtml "html"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tdewolff/parse/html"
+)
+
+// checkLinks returns an error for each root-relative href and src
+// attribute in the HTML files in build that refers to no file in build.
+// A link to a directory refers to its index.html file. Links with a
+// scheme or host, relative links, and fragment-only links are not
+// checked. If basePath is non-empty, links must start with it. The
+// errors are returned as BuildErrors.
+func checkLinks(build, basePath string) error {
+	var errs BuildErrors
+	err := filepath.Walk(build, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(p) != ".html" {
+			return nil
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		for _, link := range pageLinks(b) {
+			ok, err := linkExists(build, basePath, link)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				errs = append(errs, &fileError{p, fmt.Errorf("broken link %q", link)})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errs.err()
+}
+
+// pageLinks returns the values of the href and src attributes in the
+// HTML document b, in document order, without duplicates.
+func pageLinks(b []byte) []string {
+	var links []string
+	seen := make(map[string]bool)
+	l := html.NewLexer(bytes.NewReader(b))
+	for {
+		tt, _ := l.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.AttributeToken {
+			continue
+		}
+		if name := string(l.Text()); name != "href" && name != "src" {
+			continue
+		}
+		v := stdhtml.UnescapeString(strings.Trim(string(l.AttrVal()), `"'`))
+		if !seen[v] {
+			seen[v] = true
+			links = append(links, v)
+		}
+	}
+}
+
+// linkExists reports whether link refers to a file in build. A link
+// without an extension, such as "/about", also refers to the same name
+// with ".html", such as "about.html". Links that are not checked are
+// reported to exist.
+func linkExists(build, basePath, link string) (bool, error) {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return true, nil
+	}
+	p := path.Clean(u.Path)
+	if basePath != "" {
+		if p != basePath && !strings.HasPrefix(p, basePath+"/") {
+			return false, nil
+		}
+		p = "/" + strings.TrimPrefix(p, basePath)
+	}
+
+	name := filepath.Join(build, filepath.FromSlash(p))
+	info, err := os.Stat(name)
+	if os.IsNotExist(err) && path.Ext(p) == "" {
+		// Static hosts commonly serve "/about" from "about.html".
+		return pathExists(name + ".html")
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return true, nil
+	}
+	return pathExists(filepath.Join(name, "index.html"))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPageLinks(t *testing.T) {
+	t.Parallel()
+
+	doc := `<a href="/a/">a</a><img src='/b.png'><a href=/c?x=1&amp;y=2>c</a><a href="/a/">again</a><link rel="stylesheet" href="/d.css"><p data-href="/e">`
+	expected := []string{"/a/", "/b.png", "/c?x=1&y=2", "/d.css"}
+	if got := pageLinks([]byte(doc)); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("pageLinks: got %q, expected %q", got, expected)
+	}
+}
+
+func TestBuildCheckLinks(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html":    `<a href="/about">about</a> <a href="/blog/post/#top">post</a> <img src="/img/a.png"> <a href="https://example.com/missing">external</a> <a href="//cdn.example.com/x.js">cdn</a> <a href="#section">anchor</a> <a href="mailto:a@example.com">mail</a>`,
+		"src/about.html":    `<a href="/missing">missing</a> <a href="/blog/keep">keep</a> <script src="/js/missing.js"></script> <a href="/">home</a>`,
+		"src/layout.tmpl":   "{{ .Current.Content }}",
+		"src/blog/post.md":  "[broken](/blog/nope/) and [fine](/about.html)\n",
+		"src/img/a.png":     "png",
+		"src/blog/keep.txt": "",
+	})
+	t.Chdir(dir)
+
+	err := (&Build{CheckLinks: true}).Run()
+	var errs BuildErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Run: got error %v, expected BuildErrors", err)
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	expected := []string{
+		`build/about.html: broken link "/missing"`,
+		`build/about.html: broken link "/blog/keep"`,
+		`build/about.html: broken link "/js/missing.js"`,
+		`build/blog/post/index.html: broken link "/blog/nope/"`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Run: got errors\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// Links are prefixed with the base path.
+	writeTree(t, dir, map[string]string{
+		"src/about.html":   `<a href="/site/">home</a> <a href="/site/img/a.png">img</a>`,
+		"src/index.html":   `<a href="/img/a.png">unprefixed</a>`,
+		"src/blog/post.md": "[fine](/site/about.html)\n",
+	})
+	err = (&Build{CheckLinks: true, BasePath: "/site"}).Run()
+	if err == nil || err.Error() != `build/index.html: broken link "/img/a.png"` {
+		t.Fatalf("Run with base path: got error %v, expected broken unprefixed link", err)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// liveReloadPath is the HTTP path of the websocket endpoint that
+// browsers connect to for reload notifications.
+const liveReloadPath = "/_batsman/livereload"
+
+// liveReloadScript is injected into served HTML pages. It reloads the page
+// when a message is received on the websocket.
+const liveReloadScript = `<script>(function(){` +
+	`var ws=new WebSocket((location.protocol==="https:"?"wss://":"ws://")+location.host+"` + liveReloadPath + `");` +
+	`ws.onmessage=function(){location.reload()}` +
+	`})()</script>`
+
+// websocketGUID is the magic value from RFC 6455 used to compute
+// Sec-WebSocket-Accept.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// LiveReload is an http.Handler that accepts websocket connections from
+// browsers and notifies them to reload when Reload is called.
+//
+// Only the parts of the websocket protocol needed to send messages to the
+// browser are implemented.
+type LiveReload struct {
+	mx    sync.Mutex
+	conns map[net.Conn]bool
+}
+
+func (lr *LiveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "expected websocket upgrade", http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		stderr.Println("livereload:", err)
+		return
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	lr.mx.Lock()
+	if lr.conns == nil {
+		lr.conns = make(map[net.Conn]bool)
+	}
+	lr.conns[conn] = true
+	lr.mx.Unlock()
+
+	// Messages from the browser are not needed; read until the connection
+	// is closed so that it can be forgotten.
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, rw.Reader)
+		lr.remove(conn)
+	}()
+}
+
+func (lr *LiveReload) remove(conn net.Conn) {
+	lr.mx.Lock()
+	delete(lr.conns, conn)
+	lr.mx.Unlock()
+	conn.Close()
+}
+
+// Reload notifies all connected browsers to reload.
+func (lr *LiveReload) Reload() {
+	msg := websocketTextFrame("reload")
+
+	lr.mx.Lock()
+	conns := make([]net.Conn, 0, len(lr.conns))
+	for c := range lr.conns {
+		conns = append(conns, c)
+	}
+	lr.mx.Unlock()
+
+	for _, c := range conns {
+		if _, err := c.Write(msg); err != nil {
+			lr.remove(c)
+		}
+	}
+}
+
+// Close closes the connections to all browsers.
+func (lr *LiveReload) Close() {
+	lr.mx.Lock()
+	conns := lr.conns
+	lr.conns = nil
+	lr.mx.Unlock()
+
+	for c := range conns {
+		c.Close()
+	}
+}
+
+// websocketTextFrame returns an unmasked, unfragmented websocket text frame
+// containing s.
+func websocketTextFrame(s string) []byte {
+	buf := bytes.Buffer{}
+	buf.WriteByte(0x81) // FIN + text opcode.
+	switch n := len(s); {
+	case n < 126:
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(126)
+		buf.WriteByte(byte(n >> 8))
+		buf.WriteByte(byte(n))
+	default:
+		buf.WriteByte(127)
+		for i := 7; i >= 0; i-- {
+			buf.WriteByte(byte(n >> (uint(i) * 8)))
+		}
+	}
+	buf.WriteString(s)
+	return buf.Bytes()
+}
+
+// injectScript wraps h so that script is inserted into successful
+// text/html responses before the closing body tag, or at the end of the
+// body if there is no closing body tag. Other responses are unchanged.
+func injectScript(h http.Handler, script string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &bufferedResponseWriter{header: w.Header()}
+		h.ServeHTTP(rec, r)
+
+		body := rec.buf.Bytes()
+		if rec.code() == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			if r.Method == http.MethodHead {
+				// There is no body to insert into; report the length a GET
+				// would have, or no length if h didn't report one.
+				if n, err := strconv.Atoi(w.Header().Get("Content-Length")); err == nil {
+					w.Header().Set("Content-Length", strconv.Itoa(n+len(script)))
+				} else {
+					w.Header().Del("Content-Length")
+				}
+			} else {
+				body = insertBeforeBodyEnd(body, []byte(script))
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			}
+		}
+		w.WriteHeader(rec.code())
+		w.Write(body)
+	})
+}
+
+// insertBeforeBodyEnd inserts b before the last closing body tag in html,
+// or appends it if there is none.
+func insertBeforeBodyEnd(html, b []byte) []byte {
+	idx := bytes.LastIndex(html, []byte("</body>"))
+	if idx == -1 {
+		return append(html, b...)
+	}
+	ret := make([]byte, 0, len(html)+len(b))
+	ret = append(ret, html[:idx]...)
+	ret = append(ret, b...)
+	return append(ret, html[idx:]...)
+}
+
+// bufferedResponseWriter is an http.ResponseWriter that buffers the
+// response body so that it can be modified before being written.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (b *bufferedResponseWriter) Header() http.Header { return b.header }
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) { return b.buf.Write(p) }
+
+func (b *bufferedResponseWriter) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+func (b *bufferedResponseWriter) code() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
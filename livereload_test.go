@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInjectScript(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"index.html": "<!doctype html><p>hello</p></body>",
+		"plain.html": "<p>no body tag</p>",
+		"style.css":  "body{color:red}",
+		"app.js":     "console.log(1)",
+	})
+	const script = "<script>reload()</script>"
+	ts := httptest.NewServer(injectScript(http.FileServer(http.Dir(dir)), script))
+	defer ts.Close()
+
+	testcases := []struct {
+		path     string
+		expected string
+	}{
+		{"/index.html", "<!doctype html><p>hello</p>" + script + "</body>"},
+		{"/plain.html", "<p>no body tag</p>" + script},
+		{"/style.css", "body{color:red}"},
+		{"/app.js", "console.log(1)"},
+	}
+
+	for _, tc := range testcases {
+		resp, err := http.Get(ts.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.expected {
+			t.Fatalf("%s: got %q, expected %q", tc.path, b, tc.expected)
+		}
+		if resp.ContentLength != int64(len(tc.expected)) {
+			t.Fatalf("%s: got Content-Length %d, expected %d", tc.path, resp.ContentLength, len(tc.expected))
+		}
+
+		resp, err = http.Head(ts.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.ContentLength != int64(len(tc.expected)) {
+			t.Fatalf("%s: HEAD: got Content-Length %d, expected %d", tc.path, resp.ContentLength, len(tc.expected))
+		}
+	}
+}
+
+func TestWebsocketTextFrame(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in     string
+		header []byte
+	}{
+		{"reload", []byte{0x81, 6}},
+		{strings.Repeat("a", 200), []byte{0x81, 126, 0, 200}},
+	}
+
+	for _, tc := range testcases {
+		res := websocketTextFrame(tc.in)
+		if !strings.HasPrefix(string(res), string(tc.header)) || !strings.HasSuffix(string(res), tc.in) ||
+			len(res) != len(tc.header)+len(tc.in) {
+			t.Fatalf("websocketTextFrame: got %v, expected header %v", res[:len(tc.header)], tc.header)
+		}
+	}
+}
@@ -2,35 +2,110 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/howeyc/fsnotify"
 )
 
 const versionString = "0.1.0"
+
+// commit and date are the VCS revision and time of the build. They are
+// set at build time, such as with
+//
+//	go build -ldflags "-X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%FT%TZ)"
+var commit, date string
+
 const helpString = `usage:
   batsman [flags] [command]
 
 commands:
   init   initialize new site at specified path
-  new    print front matter for a new markdown file to stdout
-  build  generate static files into "build" directory
+  new    print front matter for a new markdown file, or create it at specified path
+  build  generate static files into "build" directory, or render one markdown file to stdout
   serve  serve "build" directory via http
+  check  validate front matter and templates in "src" without generating files
 
 flags:
-  -http   http address to serve at (default: "localhost:8080")
-  -watch  regenerate files on change while serving (default: false)
-  -title  title in new markdown front matter (default: "")
-  -draft  whether draft = true in new markdown front matter (default: false)`
+  -header          add the "Name: Value" header to served responses; can be repeated (default: none)
+  -cachecontrol    Cache-Control header of served files other than HTML, which get "no-cache" (default: none)
+  -http            http address to serve at (default: "localhost:8080")
+  -port            port to serve at, replacing the port in -http; 0 picks a free port (default: port in -http)
+  -tls             serve over HTTPS (default: false)
+  -cert            TLS certificate file for -tls (default: self-signed certificate for localhost)
+  -key             TLS key file for -tls (default: self-signed certificate for localhost)
+  -watch           regenerate files on change while serving (default: false)
+  -nobuild         serve the existing "build" directory without generating it; ignored with -watch (default: false)
+  -open            open the served URL in the default browser (default: false)
+  -livereload      reload browser pages after regenerating in -watch mode (default: true)
+  -theme           starter site for init: "minimal", "blog", or "docs" (default: "minimal")
+  -force           let init write missing files into a non-empty path (default: false)
+  -title           title in new markdown front matter (default: derived from the file name, if any)
+  -draft           whether draft = true in new markdown front matter (default: false)
+  -o               markdown file created by "new", instead of the path argument, or file "build" writes one page to (default: "")
+  -jobs            max number of files processed concurrently (default: number of CPUs)
+  -src             source directory (default: "src")
+  -out             output directory (default: "build")
+  -baseurl         base URL of the site, used by the "absURL" template function (default: "")
+  -basepath        path the site is deployed and served under, such as "/blog" (default: "")
+  -config          config file (default: "batsman.toml")
+  -env             use the values in the config file's [env.name] section (default: "")
+  -drafts          include drafts when generating files (default: true for "serve -watch", otherwise false)
+  -draftsto        generate drafts into this directory in "build", unlinked from other pages (default: "")
+  -expired         include markdown files whose front matter expiry has passed (default: false)
+  -searchindex     write a JSON search index of pages to "build/index.json" (default: false)
+  -rss             write an RSS feed of pages to "build/feed.xml" (default: false)
+  -rssfull         include full page content rather than summaries in the RSS, JSON, and Atom feeds (default: false)
+  -jsonfeed        write a JSON feed of pages to "build/feed.json" (default: false)
+  -atom            write an Atom feed of pages to "build/atom.xml" (default: false)
+  -anchors         add "#" links to headings in markdown files (default: false)
+  -mermaid         render "mermaid" fenced code blocks in markdown files as diagrams (default: false)
+  -math            keep "$$...$$" and "$...$" math in markdown files intact for KaTeX (default: false)
+  -emoji           replace emoji shortcodes such as ":rocket:" in markdown files (default: false)
+  -plugintimeout   time limit for network requests by functions such as oEmbed (default: 10s)
+  -checkgists      fail the build if a gist used with Gist does not exist (default: false)
+  -autoindex       generate an "index.html" listing the markdown pages of directories without one (default: false)
+  -pagesize        markdown pages per page in "index.html" files, 0 to disable (default: 10)
+  -fingerprint     add content hashes to CSS, JS, and SVG file names (default: false)
+  -minify          minify generated HTML, CSS, JS, and SVG files (default: true)
+  -minifyhtml      minify generated HTML files, if -minify is set (default: true)
+  -minifycss       minify CSS files, if -minify is set (default: true)
+  -minifyjs        minify JS files, if -minify is set (default: true)
+  -minifysvg       minify SVG files, if -minify is set (default: true)
+  -robots          write a default "build/robots.txt" if "src/robots.txt" does not exist (default: true)
+  -sitemap         write a sitemap of pages, which requires -baseurl, to "build/sitemap.xml" (default: false)
+  -compress        write gzip-compressed ".gz" copies of generated text files (default: false)
+  -checklinks      report root-relative links in generated HTML that refer to no generated file (default: false)
+  -sizebudget      warn about generated HTML files larger than this many bytes; 0 to disable (default: 0)
+  -manifest        write "build/.batsman-manifest.json" listing the generated files and their hashes (default: false)
+  -optimizeimages  re-encode PNG and JPEG files to reduce their size (default: false)
+  -imagequality    JPEG quality, 1-100, used by -optimizeimages (default: 85)
+  -profile         print the number and duration of each phase of the build (default: false)
+  -quiet           print only errors (default: false)
+  -verbose         log the action taken for each file (default: false)
+  -json            print the version as JSON, with -version or "version" (default: false)
+
+flags override values in the config file, if it exists.`
 
 var (
 	perm = struct {
@@ -39,85 +114,333 @@ var (
 
 	stdout = log.New(os.Stdout, "", 0)
 	stderr = log.New(os.Stderr, "", 0)
+
+	// info is for progress messages, such as the address being served.
+	// It writes to stderr unless -quiet is set.
+	info = log.New(os.Stderr, "", 0)
 )
 
-var flags = struct {
-	HTTP  string
-	Watch bool
-	Title string
-	Draft bool
+// setQuiet discards the messages logged to info if quiet is true, and
+// otherwise sends them to the output of stderr.
+func setQuiet(quiet bool) {
+	if quiet {
+		info.SetOutput(ioutil.Discard)
+	} else {
+		info.SetOutput(stderr.Writer())
+	}
+}
+
+// cmdFlags holds the values of the command line flags.
+type cmdFlags struct {
+	HTTP          string
+	Port          int
+	Watch         bool
+	LiveReload    bool
+	Open          bool
+	CheckLinks    bool
+	SizeBudget    int
+	Manifest      bool
+	Title         string
+	Draft         bool
+	Output        string
+	Theme         string
+	Force         bool
+	TLS           bool
+	Compress      bool
+	Cert          string
+	Key           string
+	Jobs          int
+	Src           string
+	BaseURL       string
+	BasePath      string
+	Out           string
+	Config        string
+	Env           string
+	Headers       headerFlag
+	CacheControl  string
+	Drafts        bool
+	Expired       bool
+	DraftsTo      string
+	SearchIndex   bool
+	Anchors       bool
+	Emoji         bool
+	Mermaid       bool
+	Math          bool
+	PluginTimeout time.Duration
+	CheckGists    bool
+	NoBuild       bool
+	AutoIndex     bool
+	MinifyHTML    bool
+	MinifyCSS     bool
+	MinifyJS      bool
+	MinifySVG     bool
+	PageSize      int
+	Fingerprint   bool
+	Minify        bool
+	Images        bool
+	Quality       int
+	Verbose       bool
+	Quiet         bool
+	Profile       bool
+	Robots        bool
+	Sitemap       bool
+	RSS           bool
+	RSSFull       bool
+	JSONFeed      bool
+	Atom          bool
 
 	Help    bool
 	Version bool
-}{}
+	JSON    bool
+}
+
+// errUsage is returned by run for invalid usage, after the usage has
+// been printed.
+var errUsage = &ExitError{Code: ExitUsage, Err: errors.New("invalid usage")}
 
 func main() {
-	flag.StringVar(&flags.HTTP, "http", "localhost:8080", "")
-	flag.BoolVar(&flags.Watch, "watch", false, "")
-	flag.StringVar(&flags.Title, "title", "", "")
-	flag.BoolVar(&flags.Draft, "draft", false, "")
-	flag.BoolVar(&flags.Help, "help", false, "")
-	flag.BoolVar(&flags.Version, "version", false, "")
-
-	flag.Usage = func() {
+	os.Exit(exit(run(os.Args[1:])))
+}
+
+// exit prints err, if it is non-nil and has not already been reported,
+// and returns the exit code for err.
+func exit(err error) int {
+	if err != nil && err != errUsage {
+		stderr.Println("batsman: error:", err)
+	}
+	return exitCode(err)
+}
+
+// run parses the command line arguments args, without the program name,
+// and runs the command.
+func run(args []string) error {
+	var flags cmdFlags
+	fs := flag.NewFlagSet("batsman", flag.ContinueOnError)
+	fs.SetOutput(stderr.Writer())
+	fs.IntVar(&flags.Port, "port", 0, "")
+	fs.StringVar(&flags.HTTP, "http", defaultConfig.HTTP, "")
+	fs.BoolVar(&flags.Watch, "watch", false, "")
+	fs.BoolVar(&flags.LiveReload, "livereload", true, "")
+	fs.BoolVar(&flags.Open, "open", false, "")
+	fs.BoolVar(&flags.CheckLinks, "checklinks", false, "")
+	fs.IntVar(&flags.SizeBudget, "sizebudget", 0, "")
+	fs.BoolVar(&flags.Manifest, "manifest", false, "")
+	fs.StringVar(&flags.Title, "title", "", "")
+	fs.BoolVar(&flags.Draft, "draft", false, "")
+	fs.StringVar(&flags.Output, "o", "", "")
+	fs.StringVar(&flags.Theme, "theme", DefaultTheme, "")
+	fs.BoolVar(&flags.Force, "force", false, "")
+	fs.BoolVar(&flags.TLS, "tls", false, "")
+	fs.BoolVar(&flags.Compress, "compress", false, "")
+	fs.StringVar(&flags.Cert, "cert", "", "")
+	fs.StringVar(&flags.Key, "key", "", "")
+	fs.IntVar(&flags.Jobs, "jobs", 0, "")
+	fs.StringVar(&flags.Src, "src", defaultConfig.Src, "")
+	fs.StringVar(&flags.BaseURL, "baseurl", "", "")
+	fs.StringVar(&flags.BasePath, "basepath", "", "")
+	fs.StringVar(&flags.Out, "out", defaultConfig.Out, "")
+	fs.StringVar(&flags.Config, "config", DefaultConfigFile, "")
+	fs.StringVar(&flags.Env, "env", "", "")
+	fs.Var(&flags.Headers, "header", "")
+	fs.StringVar(&flags.CacheControl, "cachecontrol", "", "")
+	fs.BoolVar(&flags.Expired, "expired", false, "")
+	fs.StringVar(&flags.DraftsTo, "draftsto", "", "")
+	fs.BoolVar(&flags.Drafts, "drafts", false, "")
+	fs.BoolVar(&flags.SearchIndex, "searchindex", false, "")
+	fs.BoolVar(&flags.Anchors, "anchors", false, "")
+	fs.BoolVar(&flags.Emoji, "emoji", false, "")
+	fs.BoolVar(&flags.Mermaid, "mermaid", false, "")
+	fs.BoolVar(&flags.Math, "math", false, "")
+	fs.DurationVar(&flags.PluginTimeout, "plugintimeout", DefaultPluginTimeout, "")
+	fs.BoolVar(&flags.CheckGists, "checkgists", false, "")
+	fs.BoolVar(&flags.NoBuild, "nobuild", false, "")
+	fs.BoolVar(&flags.AutoIndex, "autoindex", false, "")
+	fs.IntVar(&flags.PageSize, "pagesize", defaultConfig.PageSize, "")
+	fs.BoolVar(&flags.Fingerprint, "fingerprint", false, "")
+	fs.BoolVar(&flags.Minify, "minify", defaultConfig.Minify, "")
+	fs.BoolVar(&flags.MinifyHTML, "minifyhtml", true, "")
+	fs.BoolVar(&flags.MinifyCSS, "minifycss", true, "")
+	fs.BoolVar(&flags.MinifyJS, "minifyjs", true, "")
+	fs.BoolVar(&flags.MinifySVG, "minifysvg", true, "")
+	fs.BoolVar(&flags.Images, "optimizeimages", false, "")
+	fs.IntVar(&flags.Quality, "imagequality", DefaultImageQuality, "")
+	fs.BoolVar(&flags.Verbose, "verbose", false, "")
+	fs.BoolVar(&flags.Quiet, "quiet", false, "")
+	fs.BoolVar(&flags.Profile, "profile", false, "")
+	fs.BoolVar(&flags.Robots, "robots", true, "")
+	fs.BoolVar(&flags.Sitemap, "sitemap", false, "")
+	fs.BoolVar(&flags.RSS, "rss", false, "")
+	fs.BoolVar(&flags.RSSFull, "rssfull", false, "")
+	fs.BoolVar(&flags.JSONFeed, "jsonfeed", false, "")
+	fs.BoolVar(&flags.Atom, "atom", false, "")
+	fs.BoolVar(&flags.Help, "help", false, "")
+	fs.BoolVar(&flags.Version, "version", false, "")
+	fs.BoolVar(&flags.JSON, "json", false, "")
+
+	fs.Usage = func() {
 		stderr.Println(helpString)
-		os.Exit(2)
 	}
-	flag.Parse()
+	if err := fs.Parse(args); err != nil {
+		// fs.Usage has printed the usage. Like an unknown flag, -h
+		// exits with ExitUsage; -help prints the usage and succeeds.
+		return errUsage
+	}
 
 	if flags.Help {
 		stdout.Println(helpString)
-		os.Exit(0)
+		return nil
 	}
 	if flags.Version {
-		stdout.Println("v" + versionString)
-		os.Exit(0)
+		return printVersion(flags.JSON)
 	}
 
-	command := flag.Arg(0)
+	command := fs.Arg(0)
 	switch command {
 	case "":
 		stderr.Println(helpString)
-		os.Exit(2)
+		return errUsage
 	case "help":
 		stdout.Println(helpString)
-		os.Exit(0)
+		return nil
 	case "version":
-		stdout.Println("v" + versionString)
-		os.Exit(0)
+		return printVersion(flags.JSON)
+	}
+
+	setQuiet(flags.Quiet)
+	pluginClient.Timeout = flags.PluginTimeout
+	CheckGists = flags.CheckGists
+
+	config, err := LoadConfig(flags.Config, flags.Env)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	config.override(fs)
+
+	build := &Build{
+		Funcs: DefaultFuncs(),
+		Src:   config.Src,
+		Out:   config.Out,
+
+		// Drafts are included by default only when previewing with
+		// "serve -watch", unless the config file or -drafts says
+		// otherwise.
+		Drafts:   command == "serve" && flags.Watch,
+		Expired:  flags.Expired,
+		DraftsTo: flags.DraftsTo,
+
+		NoMinifyExts: map[string]bool{
+			".html": !flags.MinifyHTML,
+			".css":  !flags.MinifyCSS,
+			".js":   !flags.MinifyJS,
+			".svg":  !flags.MinifySVG,
+		},
+		SearchIndex:    flags.SearchIndex,
+		HeadingAnchors: flags.Anchors,
+		Emoji:          flags.Emoji,
+		Mermaid:        flags.Mermaid,
+		Math:           flags.Math,
+		AutoIndex:      flags.AutoIndex,
+		Fingerprint:    flags.Fingerprint,
+		OptimizeImages: flags.Images,
+		ImageQuality:   flags.Quality,
+		Verbose:        flags.Verbose,
+		Profile:        flags.Profile,
+		Robots:         flags.Robots,
+		Compress:       flags.Compress,
+		CheckLinks:     flags.CheckLinks,
+		SizeBudget:     flags.SizeBudget,
+		Manifest:       flags.Manifest,
+	}
+	config.apply(build)
+
+	headers, err := parseHeaders(config.Headers)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
 	}
 
 	switch command {
 	case "init":
-		do(&Initialize{flag.Arg(1)})
+		return (&Initialize{Path: fs.Arg(1), Theme: flags.Theme, Force: flags.Force}).Run()
 	case "new":
-		do(&New{
+		out := flags.Output
+		if out == "" {
+			out = fs.Arg(1)
+		}
+		return (&New{
 			Title: flags.Title,
 			Draft: flags.Draft,
-		})
+			Out:   out,
+			Src:   config.Src,
+
+			FrontMatterSep: config.FrontMatterSep,
+		}).Run()
 	case "build":
-		do(&Build{funcs})
+		if page := fs.Arg(1); page != "" {
+			return (&BuildPage{Build: build, Page: page, Out: flags.Output}).Run()
+		}
+		if err := build.Run(); err != nil {
+			return err
+		}
+		info.Println(build.counts)
+		return nil
+	case "check":
+		return (&Check{Funcs: DefaultFuncs(), Src: config.Src, FrontMatterSep: config.FrontMatterSep}).Run()
 	case "serve":
-		do(&Serve{
-			Watch: flags.Watch,
-			HTTP:  flags.HTTP,
-		})
+		return (&Serve{
+			Build:        build,
+			Watch:        flags.Watch,
+			LiveReload:   flags.LiveReload,
+			Open:         flags.Open,
+			HTTP:         config.HTTP,
+			TLS:          flags.TLS,
+			CertFile:     flags.Cert,
+			KeyFile:      flags.Key,
+			NoBuild:      flags.NoBuild,
+			Headers:      headers,
+			CacheControl: flags.CacheControl,
+			ConfigFile:   flags.Config,
+			Reload: func(b *Build) error {
+				config, err := LoadConfig(flags.Config, flags.Env)
+				if err != nil {
+					return fmt.Errorf("config: %w", err)
+				}
+				config.override(fs)
+				config.apply(b)
+				return nil
+			},
+		}).Run()
 	default:
 		stderr.Printf("unknown command %q\n", command)
 		stderr.Println(`run "batsman -help" for usage`)
-		os.Exit(2)
+		return errUsage
 	}
 }
 
-// do runs Cmd and exits with exit code 1 if the
-// returned error is non-nil or with exit code 0 if
-// the error is nil.
-func do(cmd Cmd) {
-	if err := cmd.Run(); err != nil {
-		stderr.Println("batsman: error:", err)
-		os.Exit(1)
+// versionInfo is the version information printed with -json.
+type versionInfo struct {
+	Version string `json:"version"`
+	Go      string `json:"go"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"date,omitempty"`
+}
+
+// printVersion prints the version to stdout, as JSON if asJSON is true.
+func printVersion(asJSON bool) error {
+	if !asJSON {
+		stdout.Println("v" + versionString)
+		return nil
 	}
-	os.Exit(0)
+	b, err := json.Marshal(versionInfo{
+		Version: versionString,
+		Go:      runtime.Version(),
+		Commit:  commit,
+		Date:    date,
+	})
+	if err != nil {
+		return err
+	}
+	stdout.Println(string(b))
+	return nil
 }
 
 type Cmd interface {
@@ -125,41 +448,128 @@ type Cmd interface {
 	Run() error
 }
 
+// BuildPage renders a single markdown file with Build.RenderPage.
+type BuildPage struct {
+	Build *Build
+	Page  string // Markdown file to render.
+
+	// Out is the file to write the page to. If empty, the page is
+	// printed to stdout.
+	Out string
+}
+
+func (b *BuildPage) Run() error {
+	buf := bytes.Buffer{}
+	if err := b.Build.RenderPage(b.Page, &buf); err != nil {
+		return err
+	}
+	if b.Out == "" {
+		stdout.Print(buf.String())
+		return nil
+	}
+	return createFileWithData(b.Out, &buf)
+}
+
 type New struct {
 	Title string
 	Draft bool
+
+	// Out is the markdown file to create. If empty, the front matter
+	// is printed to stdout. If Title is empty, the title is derived
+	// from the file name.
+	Out string
+
+	// Src is the source directory, whose ArchetypesDir has templates
+	// for the new file. If empty, "src" is used. Without an archetype,
+	// the file only has front matter.
+	Src string
+
+	// FrontMatterSep is the separator around the front matter. If
+	// empty, FrontMatterSep is used.
+	FrontMatterSep string
 }
 
 func (n *New) Run() error {
-	stdout.Print(&FrontMatter{
-		Title: n.Title,
+	title := n.Title
+	if title == "" && n.Out != "" {
+		title = humanizeFilename(filepath.Base(n.Out))
+	}
+	fm := &FrontMatter{
+		Title: title,
 		Draft: n.Draft,
 		Time:  time.Now(),
-	})
-	return nil
+	}
+	sep := n.FrontMatterSep
+	if sep == "" {
+		sep = FrontMatterSep
+	}
+	contents := []byte(fm.format(sep))
+
+	arch, err := findArchetype((&Build{Src: n.Src}).srcDir(), n.Out)
+	if err != nil {
+		return err
+	}
+	if arch != "" {
+		data := archetypeData{Title: fm.Title, Date: fm.Time.Format(defaultTimeFormat), Draft: fm.Draft}
+		if contents, err = renderArchetype(arch, data); err != nil {
+			return err
+		}
+	}
+
+	if n.Out == "" {
+		stdout.Print(string(contents))
+		return nil
+	}
+
+	exists, err := pathExists(n.Out)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("file %q already exists", n.Out)
+	}
+	return createFileWithData(n.Out, bytes.NewReader(contents))
 }
 
 type Initialize struct {
-	Path string // Path to initialize new site at.
+	Path  string // Path to initialize new site at.
+	Theme string // Name of the starter site. If empty, DefaultTheme is used.
+
+	// Force indicates whether to initialize a non-empty Path. Only
+	// files that do not already exist are written.
+	Force bool
 }
 
+// maxListedEntries is the maximum number of existing entries listed in
+// the error for a non-empty init path.
+const maxListedEntries = 5
+
 func (init *Initialize) Run() error {
 	if init.Path == "" {
 		return errors.New("init requires path argument\nexample: batsman init path/to/new/site")
 	}
+	theme := init.Theme
+	if theme == "" {
+		theme = DefaultTheme
+	}
+	files, err := themeFiles(theme)
+	if err != nil {
+		return err
+	}
 
 	root := init.Path
 	exists, err := pathExists(root)
 	if err != nil {
 		return err
 	}
+	empty := true
 	if exists {
-		empty, err := isEmpty(root)
+		names, err := dirNames(root)
 		if err != nil {
 			return err
 		}
-		if !empty {
-			return fmt.Errorf("path %q not empty", root)
+		if empty = len(names) == 0; !empty && !init.Force {
+			return &nonEmptyError{root, names}
 		}
 	}
 
@@ -167,29 +577,48 @@ func (init *Initialize) Run() error {
 		return err
 	}
 
+	var written struct {
+		sync.Mutex
+		names []string
+	}
 	success := false
 	defer func() {
-		// Cleanup.
-		if !success {
+		// Cleanup. Only remove what was written if root had other
+		// contents.
+		if success {
+			return
+		}
+		if empty {
 			_ = os.RemoveAll(root) // Ignore error.
+			return
+		}
+		for _, name := range written.names {
+			_ = os.Remove(name) // Ignore error.
 		}
 	}()
 
-	if err := os.Mkdir(filepath.Join(root, "src"), perm.dir); err != nil {
-		return err
-	}
-
 	wg := sync.WaitGroup{}
-	errs := make(chan error, len(rawFiles))
-	for k, v := range rawFiles {
+	errs := make(chan error, len(files))
+	for k, v := range files {
 		k, v := k, v
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			errs <- createFileWithData(
-				filepath.Join(root, filepath.FromSlash(k)),
-				bytes.NewReader(v),
-			)
+			name := filepath.Join(root, filepath.FromSlash(k))
+			if !empty {
+				exists, err := pathExists(name)
+				if err != nil || exists {
+					errs <- err
+					return
+				}
+			}
+			err := createFileWithData(name, bytes.NewReader(v))
+			if err == nil {
+				written.Lock()
+				written.names = append(written.names, name)
+				written.Unlock()
+			}
+			errs <- err
 		}()
 	}
 	wg.Wait()
@@ -205,58 +634,266 @@ func (init *Initialize) Run() error {
 }
 
 type Serve struct {
+	Build *Build // Build used to generate the output directory.
 	HTTP  string
 	Watch bool
+
+	// LiveReload indicates whether browsers should reload pages
+	// after the output directory is regenerated. Only applies
+	// if Watch is true.
+	LiveReload bool
+
+	// TLS indicates whether to serve over HTTPS using the
+	// certificate and key in CertFile and KeyFile. If they are
+	// empty, a self-signed certificate for localhost is used.
+	TLS               bool
+	CertFile, KeyFile string
+
+	// NoBuild indicates whether to serve the existing output
+	// directory without building it first. It does not apply if
+	// Watch is true, since watching starts with a build.
+	NoBuild bool
+
+	// Headers is added to every response.
+	Headers http.Header
+
+	// CacheControl is the Cache-Control header of responses other
+	// than HTML, whose header is "no-cache". If empty, no
+	// Cache-Control header is set.
+	CacheControl string
+
+	// Open indicates whether to open the served URL in the default
+	// browser once the server is listening. Failing to open it is
+	// not an error.
+	Open bool
+
+	// ConfigFile is the path of the config file. If Watch is true
+	// and Reload is non-nil, a change to it calls Reload to update
+	// Build and then does a full build, since any output may depend
+	// on the config. The directories and address being served are
+	// not changed.
+	ConfigFile string
+	Reload     func(b *Build) error
 }
 
+// shutdownTimeout is how long serve waits for in-flight requests to
+// finish after it is stopped.
+const shutdownTimeout = 5 * time.Second
+
+// Run serves until it is interrupted, such as by Ctrl-C, or sent
+// SIGTERM, and then shuts down gracefully.
 func (s *Serve) Run() error {
-	stderr.Println(`generating "build" directory ...`)
-	if err := (&Build{funcs}).Run(); err != nil {
+	ln, err := net.Listen("tcp", s.HTTP)
+	if err != nil {
 		return err
 	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return s.serve(ctx, ln)
+}
+
+// isConfig returns whether name is s.ConfigFile and s.Reload is set.
+func (s *Serve) isConfig(name string) bool {
+	return s.Reload != nil && s.ConfigFile != "" && samePath(name, s.ConfigFile)
+}
+
+// rebuild regenerates the output directory after the file name changed
+// while watching. A change to the config file reloads it and does a full
+// build; other changes are rebuilt with Build.Rebuild.
+func (s *Serve) rebuild(name string) error {
+	if !s.isConfig(name) {
+		return s.Build.Rebuild()
+	}
+	if err := s.Reload(s.Build); err != nil {
+		return err
+	}
+	return s.Build.Run()
+}
+
+// serve builds and serves the output directory on ln, which is closed
+// when serve returns. The resolved address of ln, which differs from
+// s.HTTP for port 0, is logged. When ctx is done, the server shuts
+// down, closing the watcher and live reload connections, and serve
+// returns nil.
+func (s *Serve) serve(ctx context.Context, ln net.Listener) error {
+	defer ln.Close()
+	src, build := s.Build.srcDir(), s.Build.outDir()
+	if s.Watch && s.Build.Templates == nil {
+		s.Build.Templates = &TemplateCache{}
+	}
+
+	if s.NoBuild && !s.Watch {
+		info, err := os.Stat(build)
+		if os.IsNotExist(err) || (err == nil && !info.IsDir()) {
+			return fmt.Errorf("%q directory does not exist; run \"batsman build\" first, or serve without -nobuild", build)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		info.Printf("generating %q directory ...\n", build)
+		if err := s.Build.Run(); err != nil {
+			return err
+		}
+		info.Println(s.Build.counts)
+	}
+
+	var handler http.Handler = http.FileServer(http.Dir(build))
+	if !s.Watch || !s.LiveReload {
+		// The live reload script cannot be inserted into compressed files.
+		handler = precompressed(build, handler)
+	}
+	handler = notFound(build, handler)
+	handler = cacheControl(s.CacheControl, handler)
+	handler = underPath(s.Build.basePath(), handler)
+	handler = markDrafts(s.Build.isDraft, handler)
+	var lr *LiveReload
+	if s.Watch && s.LiveReload {
+		lr = &LiveReload{}
+		mux := http.NewServeMux()
+		mux.Handle(liveReloadPath, lr)
+		mux.Handle("/", injectScript(handler, liveReloadScript))
+		handler = mux
+	}
 
 	if s.Watch {
 		w, err := fsnotify.NewWatcher()
 		if err != nil {
 			return err
 		}
-		defer w.Close()
-
-		if err := filepath.Walk("src", func(p string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
+		if err := watchTree(w, src, build); err != nil {
+			w.Close()
+			return err
+		}
+		if s.ConfigFile != "" && s.Reload != nil {
+			if ok, _ := pathExists(s.ConfigFile); ok {
+				if err := w.Watch(s.ConfigFile); err != nil {
+					stderr.Println("error: watch:", err)
+				}
 			}
-			if !info.IsDir() {
-				return nil
+		}
+		rebuilt := make(chan struct{})
+		defer func() {
+			w.Close()
+			<-rebuilt // Let a rebuild in progress finish.
+		}()
+
+		go func() {
+			for err := range w.Error {
+				stderr.Println("watch:", err)
 			}
-			go func() {
-				for err := range w.Error {
-					stderr.Println("watch:", err)
+		}()
+		go func() {
+			defer close(rebuilt)
+			for name := range debounce(changes(w, build), rebuildDelay) {
+				info.Printf("rebuilding change: %q ... ", name)
+				if s.isConfig(name) {
+					// Editors may replace the file, which ends the watch.
+					w.Watch(s.ConfigFile)
 				}
-			}()
-			go func() {
-				for e := range w.Event {
-					stderr.Printf("rebuilding change: %q ... ", e.Name)
-					if err := (&Build{funcs}).Run(); err != nil {
-						stderr.Println("error: rebuild:", err)
-					} else {
-						stderr.Printf("done rebuilding")
+				if err := s.rebuild(name); err != nil {
+					stderr.Println("error: rebuild:", err)
+				} else {
+					info.Println("done rebuilding")
+					if lr != nil {
+						lr.Reload()
 					}
 				}
-			}()
-			if err := w.Watch(p); err != nil {
-				stderr.Println("error: watch:", err)
 			}
-			return nil
-		}); err != nil {
+		}()
+
+		info.Printf("watching \"%s/**/*\" for changes ...\n", filepath.ToSlash(src))
+	}
+
+	handler = withHeaders(s.Headers, handler)
+	srv := &http.Server{Handler: handler}
+	if s.Open {
+		scheme := "http"
+		if s.TLS {
+			scheme = "https"
+		}
+		// ln is bound, so the browser's connection waits in the listen
+		// backlog until srv accepts it.
+		u := scheme + "://" + ln.Addr().String() + s.Build.basePath() + "/"
+		if err := openBrowser(runtime.GOOS, u); err != nil {
+			stderr.Println("warning: open browser:", err)
+		}
+	}
+	var start func() error
+	switch {
+	case !s.TLS:
+		info.Printf("serving %q directory at http://%s ...\n", build, ln.Addr())
+		start = func() error { return srv.Serve(ln) }
+	case s.CertFile != "" || s.KeyFile != "":
+		info.Printf("serving %q directory at https://%s ...\n", build, ln.Addr())
+		start = func() error { return srv.ServeTLS(ln, s.CertFile, s.KeyFile) }
+	default:
+		cert, err := selfSignedCert("localhost", "127.0.0.1", "::1")
+		if err != nil {
 			return err
 		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		info.Printf("serving %q directory at https://%s with a self-signed certificate ...\n", build, ln.Addr())
+		start = func() error { return srv.ServeTLS(ln, "", "") }
+	}
 
-		stderr.Println(`watching "src/**/*" for changes ...`)
+	errc := make(chan error, 1)
+	go func() { errc <- start() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
 	}
 
-	stderr.Printf("serving \"build\" directory on HTTP on %s ...\n", s.HTTP)
-	return http.ListenAndServe(s.HTTP, http.FileServer(http.Dir("build")))
+	info.Println("shutting down ...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	if lr != nil {
+		// Shutdown does not close the hijacked websocket connections.
+		lr.Close()
+	}
+	if err != nil {
+		return err
+	}
+	if err := <-errc; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// underPath returns a handler that serves requests under basePath with
+// h, with basePath removed from the request path, so that a site built
+// with Build.BasePath is served as it is deployed. Requests for "/"
+// redirect to basePath; other requests are not found. If basePath is
+// empty, h is returned.
+func underPath(basePath string, h http.Handler) http.Handler {
+	if basePath == "" {
+		return h
+	}
+	mux := http.NewServeMux()
+	mux.Handle(basePath+"/", http.StripPrefix(basePath, h))
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, basePath+"/", http.StatusFound)
+	}))
+	return mux
+}
+
+// humanizeFilename returns a title for the file name, such as
+// "My First Post" for "my-first-post.md".
+func humanizeFilename(name string) string {
+	words := strings.FieldsFunc(trimExt(name), func(r rune) bool {
+		return r == '-' || r == '_' || unicode.IsSpace(r)
+	})
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+	return strings.Join(words, " ")
 }
 
 func pathExists(p string) (bool, error) {
@@ -295,26 +932,103 @@ func createFileWithData(name string, data io.Reader) error {
 	return f.Sync()
 }
 
-// isEmpty returns whether a directory is empty.
-func isEmpty(name string) (bool, error) {
-	f, err := os.Open(name)
+// writeIfChanged writes data to the named file, creating the file and
+// its parent directories as needed, unless the file already contains
+// exactly data. An unchanged file is not written, so that its
+// modification time is kept. It returns whether the file was written.
+func writeIfChanged(name string, data []byte) (bool, error) {
+	if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() && info.Size() == int64(len(data)) {
+		old, err := ioutil.ReadFile(name)
+		if err == nil && bytes.Equal(old, data) {
+			return false, nil
+		}
+	}
+	return true, createFileWithData(name, bytes.NewReader(data))
+}
+
+// copyIfChanged copies the file src to dst, creating dst and its parent
+// directories as needed, unless dst already has the same contents. Unlike
+// writeIfChanged, the contents are streamed rather than read into memory,
+// so that large files, such as media, are cheap to copy. It returns
+// whether dst was written.
+func copyIfChanged(dst, src string) (bool, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
 	if err != nil {
 		return false, err
 	}
+	if sameContents(dst, in, info.Size()) {
+		return false, nil
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return true, createFileWithData(dst, in)
+}
+
+// sameContents returns whether the named file is a regular file of size
+// bytes with the same contents as r. The contents are compared in chunks,
+// and only if the sizes match.
+func sameContents(name string, r io.Reader, size int64) bool {
+	info, err := os.Stat(name)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != size {
+		return false
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return false
+	}
 	defer f.Close()
 
-	_, err = f.Readdirnames(1)
-	if err == io.EOF {
-		return true, nil
+	b1, b2 := make([]byte, 32*1024), make([]byte, 32*1024)
+	for {
+		n1, err1 := io.ReadFull(f, b1)
+		n2, err2 := io.ReadFull(r, b2)
+		if !bytes.Equal(b1[:n1], b2[:n2]) {
+			return false
+		}
+		if err1 != nil || err2 != nil {
+			// Both reach the end together for equal contents of the
+			// same size.
+			return (err1 == io.EOF || err1 == io.ErrUnexpectedEOF) && err1 == err2
+		}
 	}
-	return false, err // Either nil or error, suits both cases.
 }
 
-func copyFile(dst, src string) error {
-	in, err := os.Open(src)
+// dirNames returns the sorted names of the entries in a directory.
+func dirNames(name string) ([]string, error) {
+	f, err := os.Open(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer in.Close()
-	return createFileWithData(dst, in)
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// nonEmptyError is returned by init for a non-empty path.
+type nonEmptyError struct {
+	path  string
+	names []string // Existing entries in path.
+}
+
+func (e *nonEmptyError) Error() string {
+	names := e.names
+	more := ""
+	if len(names) > maxListedEntries {
+		more = fmt.Sprintf(", and %d more", len(names)-maxListedEntries)
+		names = names[:maxListedEntries]
+	}
+	return fmt.Sprintf("path %q not empty (contains %s%s)\nuse -force to write only the missing files",
+		e.path, strings.Join(names, ", "), more)
 }
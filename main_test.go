@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"site/src/index.html":  "<p>home</p>",
+		"site/src/layout.tmpl": "{{ .Current.Title }}",
+		"bad.toml":             "not toml",
+	})
+	t.Chdir(dir)
+
+	var out, errOut bytes.Buffer
+	stdout.SetOutput(&out)
+	stderr.SetOutput(&errOut)
+	defer stdout.SetOutput(os.Stdout)
+	defer stderr.SetOutput(os.Stderr)
+	defer setQuiet(false)
+
+	testcases := []struct {
+		args   []string
+		err    error  // Expected error; nil means no error is expected.
+		errMsg string // Substring of the error, if err is nil but an error is expected.
+		stdout string // Substring of stdout.
+		stderr string // Substring of stderr.
+	}{
+		{args: nil, err: errUsage, stderr: "usage:"},
+		{args: []string{"help"}, stdout: "usage:"},
+		{args: []string{"-help"}, stdout: "usage:"},
+		{args: []string{"-h"}, err: errUsage, stderr: "usage:"},
+		{args: []string{"version"}, stdout: "v" + versionString},
+		{args: []string{"-version"}, stdout: "v" + versionString},
+		{args: []string{"-nosuchflag", "build"}, err: errUsage, stderr: "flag provided but not defined: -nosuchflag"},
+		{args: []string{"deploy"}, err: errUsage, stderr: `unknown command "deploy"`},
+		{args: []string{"init", "mysite"}},
+		{args: []string{"init", "mysite"}, errMsg: "not empty"},
+		{args: []string{"-title", "Hello", "new"}, stdout: `title = "Hello"`},
+		{args: []string{"-title", "Post", "new", filepath.Join("site", "src", "post.md")}},
+		{args: []string{"-src", filepath.Join("site", "src"), "-out", filepath.Join("site", "build"), "build"}, stderr: "built 1 pages"},
+		{args: []string{"-src", filepath.Join("site", "src"), "check"}},
+		{args: []string{"-out", "missing", "-nobuild", "serve"}, errMsg: `"missing" directory does not exist`},
+		{args: []string{"-config", "bad.toml", "build"}, errMsg: "config:"},
+	}
+
+	for _, tc := range testcases {
+		out.Reset()
+		errOut.Reset()
+		err := run(tc.args)
+		switch {
+		case tc.err != nil:
+			if err != tc.err {
+				t.Fatalf("run(%q): got error %v, expected %v", tc.args, err, tc.err)
+			}
+		case tc.errMsg != "":
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Fatalf("run(%q): got error %v, expected %q", tc.args, err, tc.errMsg)
+			}
+		case err != nil:
+			t.Fatalf("run(%q): %s", tc.args, err)
+		}
+		if !strings.Contains(out.String(), tc.stdout) {
+			t.Fatalf("run(%q): got stdout %q, expected it to contain %q", tc.args, out.String(), tc.stdout)
+		}
+		if !strings.Contains(errOut.String(), tc.stderr) {
+			t.Fatalf("run(%q): got stderr %q, expected it to contain %q", tc.args, errOut.String(), tc.stderr)
+		}
+	}
+
+	for _, name := range []string{"mysite/src/layout.tmpl", "site/src/post.md", "site/build/index.html"} {
+		readFile(t, name)
+	}
+}
+
+func TestRunVersionJSON(t *testing.T) {
+	var out bytes.Buffer
+	stdout.SetOutput(&out)
+	defer stdout.SetOutput(os.Stdout)
+
+	for _, args := range [][]string{{"-json", "version"}, {"-version", "-json"}} {
+		out.Reset()
+		if err := run(args); err != nil {
+			t.Fatalf("run(%q): %s", args, err)
+		}
+		var v versionInfo
+		if err := json.Unmarshal(out.Bytes(), &v); err != nil {
+			t.Fatalf("run(%q): got invalid JSON %q: %s", args, out.String(), err)
+		}
+		if v.Version != versionString || v.Go != runtime.Version() {
+			t.Fatalf("run(%q): got %+v, expected version %q and go %q", args, v, versionString, runtime.Version())
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	var buf bytes.Buffer
+	stderr.SetOutput(&buf)
+	defer stderr.SetOutput(os.Stderr)
+
+	if got := exit(errUsage); got != ExitUsage || buf.Len() != 0 {
+		t.Fatalf("exit(errUsage): got %d and output %q, expected %d and no output", got, buf.String(), ExitUsage)
+	}
+	if got := exit(nil); got != 0 || buf.Len() != 0 {
+		t.Fatalf("exit(nil): got %d and output %q, expected 0 and no output", got, buf.String())
+	}
+}
+
+func TestCopyIfChanged(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.bin"), filepath.Join(dir, "out", "dst.bin")
+
+	// Larger than a chunk, so that the comparison reads more than once.
+	data := bytes.Repeat([]byte("0123456789"), 5000)
+	writeTree(t, dir, map[string]string{"src.bin": string(data)})
+	if written, err := copyIfChanged(dst, src); err != nil || !written {
+		t.Fatalf("copyIfChanged: got %t, %v, expected true, nil", written, err)
+	}
+	if got := readFile(t, dst); got != string(data) {
+		t.Fatalf("%s: got %d bytes, expected %d bytes", dst, len(got), len(data))
+	}
+	if written, err := copyIfChanged(dst, src); err != nil || written {
+		t.Fatalf("copyIfChanged unchanged: got %t, %v, expected false, nil", written, err)
+	}
+
+	// Same size, different contents.
+	data[len(data)-1] = 'x'
+	writeTree(t, dir, map[string]string{"src.bin": string(data)})
+	if written, err := copyIfChanged(dst, src); err != nil || !written {
+		t.Fatalf("copyIfChanged changed: got %t, %v, expected true, nil", written, err)
+	}
+	if got := readFile(t, dst); got != string(data) {
+		t.Fatalf("%s: got stale contents", dst)
+	}
+}
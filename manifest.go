@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestFile is the name of the manifest file in the output directory.
+const ManifestFile = ".batsman-manifest.json"
+
+// manifest lists the files generated by a build, for deployment
+// tools.
+//
+// Example manifest:
+//
+//	{
+//	  "files": [
+//	    {
+//	      "path": "blog/hello/index.html",
+//	      "source": "src/blog/hello.md",
+//	      "size": 1024,
+//	      "sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+//	    }
+//	  ]
+//	}
+type manifest struct {
+	Files []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Path   string `json:"path"`             // Slash-separated path relative to the output directory.
+	Source string `json:"source,omitempty"` // File it was generated from, if any.
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"` // Hex SHA-256 of the contents.
+}
+
+// writeManifest writes the manifest of the generated files to
+// ManifestFile in build. sources maps each file generated by the build
+// to the file it was generated from, or "". Other files in build, such
+// as those left from earlier builds, are not listed.
+func writeManifest(build string, sources map[string]string) error {
+	name := filepath.Join(build, ManifestFile)
+	m := manifest{Files: []manifestFile{}}
+	for p, src := range sources {
+		if p == name {
+			continue
+		}
+		rel, err := filepath.Rel(build, p)
+		if err != nil {
+			return err
+		}
+		size, sum, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, manifestFile{
+			Path:   filepath.ToSlash(rel),
+			Source: filepath.ToSlash(src),
+			Size:   size,
+			SHA256: sum,
+		})
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	_, err := writeIfChanged(name, buf.Bytes())
+	return err
+}
+
+// hashFile returns the size and the hex SHA-256 of the named file.
+func hashFile(name string) (int64, string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
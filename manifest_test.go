@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":  `{{ .Current.Content }}`,
+		"src/index.html":   "home",
+		"src/blog/post.md": "post",
+		"src/css/site.css": "body {}",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{Manifest: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal([]byte(readFile(t, filepath.Join("build", ManifestFile))), &m); err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[string]manifestFile)
+	for _, f := range m.Files {
+		listed[f.Path] = f
+	}
+	if _, ok := listed[ManifestFile]; ok {
+		t.Fatalf("got %s in the manifest, expected it to be excluded", ManifestFile)
+	}
+
+	n := 0
+	err := filepath.Walk("build", func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() == ManifestFile {
+			return err
+		}
+		n++
+		rel, _ := filepath.Rel("build", p)
+		f, ok := listed[filepath.ToSlash(rel)]
+		if !ok {
+			t.Fatalf("%s: missing from the manifest", p)
+		}
+		sum := sha256.Sum256([]byte(readFile(t, p)))
+		if f.SHA256 != hex.EncodeToString(sum[:]) || f.Size != info.Size() {
+			t.Fatalf("%s: got sha256 %s and size %d, expected %x and %d", p, f.SHA256, f.Size, sum, info.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(m.Files) {
+		t.Fatalf("got %d files in the manifest, expected %d", len(m.Files), n)
+	}
+
+	for p, src := range map[string]string{
+		"index.html":           "src/index.html",
+		"blog/post/index.html": "src/blog/post.md",
+		"css/site.css":         "src/css/site.css",
+	} {
+		if got := listed[p].Source; got != src {
+			t.Fatalf("%s: got source %q, expected %q", p, got, src)
+		}
+	}
+
+	// Files left in build by earlier builds are not listed, and an
+	// incremental rebuild lists the outputs of the earlier run too.
+	writeTree(t, dir, map[string]string{"build/stale.html": "old"})
+	b := &Build{Manifest: true}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join("src", "index.html"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+	m = manifest{}
+	if err := json.Unmarshal([]byte(readFile(t, filepath.Join("build", ManifestFile))), &m); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range m.Files {
+		paths = append(paths, f.Path)
+	}
+	if expected := []string{"blog/post/index.html", "css/site.css", "index.html"}; !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("after rebuild: got files %q, expected %q", paths, expected)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"html/template"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/russross/blackfriday"
+)
+
+// ExtensionTaskLists is a markdown extension that renders list items
+// beginning with "[ ]" or "[x]" as checkboxes, as in GitHub-flavored
+// markdown. Unlike the other extensions, it is not a blackfriday
+// extension; batsman applies it to the rendered HTML.
+const ExtensionTaskLists = 1 << 30
+
+// DefaultMarkdownExtensions is the markdown extensions used if
+// Build.MarkdownExtensions is zero. They are close to GitHub-flavored
+// markdown: tables, fenced code, autolinks, strikethrough, and task
+// lists, among others. EXTENSION_AUTO_HEADER_IDS is not included:
+// batsman adds ids to headings without one itself.
+const DefaultMarkdownExtensions = blackfriday.EXTENSION_NO_INTRA_EMPHASIS |
+	blackfriday.EXTENSION_TABLES |
+	blackfriday.EXTENSION_FENCED_CODE |
+	blackfriday.EXTENSION_AUTOLINK |
+	blackfriday.EXTENSION_STRIKETHROUGH |
+	blackfriday.EXTENSION_SPACE_HEADERS |
+	blackfriday.EXTENSION_HEADER_IDS |
+	blackfriday.EXTENSION_BACKSLASH_LINE_BREAK |
+	blackfriday.EXTENSION_DEFINITION_LISTS |
+	blackfriday.EXTENSION_FOOTNOTES |
+	ExtensionTaskLists
+
+// renderMarkdown renders the markdown in src to HTML with the extensions,
+// a combination of blackfriday's EXTENSION_* values and
+// ExtensionTaskLists. The ids of footnotes, such as "fn:1", include
+// footnotePrefix after the colon.
+func renderMarkdown(src []byte, extensions int, footnotePrefix string) template.HTML {
+	// NOTE(nishanths): The Renderer returned by HtmlRenderer is not safe for
+	// concurrent use, so create one each time.
+	renderer := blackfriday.HtmlRendererWithParameters(blackfridayHTMLFlags, "", "", blackfriday.HtmlRendererParameters{
+		FootnoteAnchorPrefix:       footnotePrefix,
+		FootnoteReturnLinkContents: "↩",
+	})
+	out := template.HTML(blackfriday.Markdown(src, renderer, extensions&^ExtensionTaskLists))
+	if extensions&ExtensionTaskLists != 0 {
+		out = renderTaskLists(out)
+	}
+	return out
+}
+
+// footnotePrefix returns the prefix for the footnote ids of the markdown
+// file p in root, such as "blog-hello-" for "blog/hello.md", so that the
+// ids of pages shown together, as in a listing, do not collide.
+func footnotePrefix(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		rel = filepath.Base(p)
+	}
+	return slugifyHeading(strings.Replace(filepath.ToSlash(trimExt(rel)), "/", " ", -1)) + "-"
+}
+
+var taskListItem = regexp.MustCompile(`<li>(<p>)?\[([ xX])\] `)
+
+// renderTaskLists replaces the "[ ]" or "[x]" at the start of list items
+// in content with a disabled checkbox, which is checked for "[x]".
+func renderTaskLists(content template.HTML) template.HTML {
+	return template.HTML(taskListItem.ReplaceAllStringFunc(string(content), func(m string) string {
+		sub := taskListItem.FindStringSubmatch(m)
+		checked := ""
+		if sub[2] != " " {
+			checked = " checked"
+		}
+		return `<li class="task-list-item">` + sub[1] + `<input type="checkbox" disabled` + checked + `> `
+	}))
+}
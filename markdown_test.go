@@ -0,0 +1,110 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/russross/blackfriday"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in         string
+		extensions int
+		expected   []string // Substrings of the output.
+	}{
+		{
+			"| a | b |\n|---|---|\n| 1 | 2 |\n",
+			DefaultMarkdownExtensions,
+			[]string{"<table>", "<th>a</th>", "<td>2</td>"},
+		},
+		{"~~gone~~", DefaultMarkdownExtensions, []string{"<del>gone</del>"}},
+		{
+			"- [ ] todo\n- [x] done\n- [X] also done\n- [link](/a)\n",
+			DefaultMarkdownExtensions,
+			[]string{
+				`<li class="task-list-item"><input type="checkbox" disabled> todo</li>`,
+				`<li class="task-list-item"><input type="checkbox" disabled checked> done</li>`,
+				`<li class="task-list-item"><input type="checkbox" disabled checked> also done</li>`,
+				`<li><a href="/a">link</a></li>`,
+			},
+		},
+		{
+			"- [ ] loose\n\n- [x] list\n",
+			DefaultMarkdownExtensions,
+			[]string{`<li class="task-list-item"><p><input type="checkbox" disabled> loose</p>`},
+		},
+		{"https://example.com", DefaultMarkdownExtensions, []string{`<a href="https://example.com">`}},
+		{"```\ncode\n```\n", DefaultMarkdownExtensions, []string{"<pre><code>code\n</code></pre>"}},
+
+		// Without the extensions.
+		{"~~gone~~", blackfriday.EXTENSION_TABLES, []string{"<p>~~gone~~</p>"}},
+		{"- [ ] todo\n", blackfriday.EXTENSION_TABLES, []string{"<li>[ ] todo</li>"}},
+	}
+
+	for _, tc := range testcases {
+		got := string(renderMarkdown([]byte(tc.in), tc.extensions, ""))
+		for _, s := range tc.expected {
+			if !strings.Contains(got, s) {
+				t.Fatalf("renderMarkdown(%q): got %q, expected it to contain %q", tc.in, got, s)
+			}
+		}
+	}
+}
+
+func TestRenderMarkdownFootnotes(t *testing.T) {
+	t.Parallel()
+
+	got := string(renderMarkdown([]byte("Text[^1].\n\n[^1]: A note.\n"), DefaultMarkdownExtensions, "blog-hello-"))
+	for _, s := range []string{
+		`<sup class="footnote-ref" id="fnref:blog-hello-1"><a rel="footnote" href="#fn:blog-hello-1">1</a></sup>`,
+		`<li id="fn:blog-hello-1">A note.`,
+		`<a class="footnote-return" href="#fnref:blog-hello-1">↩</a>`,
+	} {
+		if !strings.Contains(got, s) {
+			t.Fatalf("renderMarkdown: got %q, expected it to contain %q", got, s)
+		}
+	}
+}
+
+func TestFootnotePrefix(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		p, expected string
+	}{
+		{filepath.Join("src", "hello.md"), "hello-"},
+		{filepath.Join("src", "blog", "Hello World.md"), "blog-hello-world-"},
+		{filepath.Join("src", "blog", "2016", "a.markdown"), "blog-2016-a-"},
+	}
+
+	for _, tc := range testcases {
+		if got := footnotePrefix("src", tc.p); got != tc.expected {
+			t.Fatalf("footnotePrefix(%q): got %q, expected %q", tc.p, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildFootnotes(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `{{ .Current.Content }}`,
+		"src/blog/a.md":   "A[^1].\n\n[^1]: Note a.\n",
+		"src/blog/b.md":   "B[^1].\n\n[^1]: Note b.\n",
+		"src/index.html":  `{{ range index .All "blog" }}{{ .Content }}{{ end }}`,
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{NoMinify: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	index := readFile(t, "build/index.html")
+	for _, id := range []string{`id="fn:blog-a-1"`, `id="fn:blog-b-1"`, `id="fnref:blog-a-1"`, `id="fnref:blog-b-1"`} {
+		if strings.Count(index, id) != 1 {
+			t.Fatalf("build/index.html: got %q, expected one %s", index, id)
+		}
+	}
+}
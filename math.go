@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+)
+
+// mathScript is the elements that load KaTeX and render the math
+// delimited by "$$" and "$". Layouts include it with the "mathScript"
+// function when Current.HasMath is true.
+const mathScript = `<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/katex@0.16.9/dist/katex.min.css">` +
+	`<script defer src="https://cdn.jsdelivr.net/npm/katex@0.16.9/dist/katex.min.js"></script>` +
+	`<script defer src="https://cdn.jsdelivr.net/npm/katex@0.16.9/dist/contrib/auto-render.min.js" ` +
+	`onload="renderMathInElement(document.body,{delimiters:[{left:'$$',right:'$$',display:true},{left:'$',right:'$',display:false}]})"></script>`
+
+var (
+	// mathSpan matches "$$...$$", which may span lines, and "$...$"
+	// within a line, whose content does not start or end with a space,
+	// so that amounts such as "$5 and $10" are not math.
+	mathSpan = regexp.MustCompile(`(?s)\$\$.+?\$\$|\$[^\s$](?:[^$\n]*[^\s$])?\$`)
+
+	// mathCode matches fenced code blocks and code spans, in which
+	// dollar signs are not math delimiters.
+	mathCode = regexp.MustCompile("(?ms)^```.*?^```|`[^`\n]+`")
+
+	mathPlaceholder = regexp.MustCompile(`batsmanmath(\d+)x`)
+)
+
+// protectMath replaces the math spans in the markdown md with
+// placeholders that markdown rendering leaves unchanged, so that
+// characters such as "_" and "*" in math are not taken as emphasis. It
+// returns the result and the replaced spans, for restoreMath. Dollar
+// signs escaped with "\" and those in code are not delimiters.
+func protectMath(md []byte) ([]byte, []string) {
+	var spans []string
+	out := bytes.Buffer{}
+	protect := func(text []byte) {
+		last := 0
+		for _, loc := range mathSpan.FindAllIndex(text, -1) {
+			if loc[0] > 0 && text[loc[0]-1] == '\\' {
+				continue
+			}
+			out.Write(text[last:loc[0]])
+			fmt.Fprintf(&out, "batsmanmath%dx", len(spans))
+			spans = append(spans, string(text[loc[0]:loc[1]]))
+			last = loc[1]
+		}
+		out.Write(text[last:])
+	}
+
+	last := 0
+	for _, loc := range mathCode.FindAllIndex(md, -1) {
+		protect(md[last:loc[0]])
+		out.Write(md[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	protect(md[last:])
+	return out.Bytes(), spans
+}
+
+// restoreMath replaces the placeholders made by protectMath in the
+// rendered content with the escaped spans, delimiters included. It
+// reports whether content has math.
+func restoreMath(content template.HTML, spans []string) (template.HTML, bool) {
+	if len(spans) == 0 {
+		return content, false
+	}
+	restored := mathPlaceholder.ReplaceAllStringFunc(string(content), func(s string) string {
+		i, err := strconv.Atoi(mathPlaceholder.FindStringSubmatch(s)[1])
+		if err != nil || i >= len(spans) {
+			return s
+		}
+		return template.HTMLEscapeString(spans[i])
+	})
+	return template.HTML(restored), true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"html/template"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProtectMath(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in    string
+		spans []string
+	}{
+		{"The area is $\\pi r^2$ and $a_1 * b_1$.\n", []string{"$\\pi r^2$", "$a_1 * b_1$"}},
+		{"$$\n\\sum_{i=1}^n x_i * y_i\n$$\n", []string{"$$\n\\sum_{i=1}^n x_i * y_i\n$$"}},
+		{"$x < y$ & $$a_b$$\n", []string{"$x < y$", "$$a_b$$"}},
+		{"It costs $5 and $10.\n", nil},
+		{"Not math: \\$a_b$ or `$a_b$`.\n", nil},
+		{"```\n$a_b$\n```\n", nil},
+	}
+
+	for _, tc := range testcases {
+		md, spans := protectMath([]byte(tc.in))
+		if !reflect.DeepEqual(spans, tc.spans) {
+			t.Fatalf("protectMath %q: got spans %q, expected %q", tc.in, spans, tc.spans)
+		}
+		got, has := restoreMath(renderMarkdown(md, DefaultMarkdownExtensions, ""), spans)
+		if has != (len(tc.spans) > 0) {
+			t.Fatalf("restoreMath %q: got %t, expected %t", tc.in, has, len(tc.spans) > 0)
+		}
+		for _, span := range tc.spans {
+			if !strings.Contains(string(got), template.HTMLEscapeString(span)) {
+				t.Fatalf("restoreMath %q: got %q, expected it to contain %q", tc.in, got, span)
+			}
+		}
+	}
+}
+
+func TestBuildMath(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `{{ .Current.Content }}{{ if .Current.HasMath }}{{ mathScript }}{{ end }}`,
+		"src/math.md":     "Inline $a *b* c$.\n\n$$\nx *y* z\n$$\n",
+		"src/plain.md":    "No math, just $5.\n",
+	})
+	t.Chdir(dir)
+
+	for _, math := range []bool{false, true} {
+		if err := (&Build{NoMinify: true, Math: math}).Run(); err != nil {
+			t.Fatal(err)
+		}
+		got := readFile(t, "build/math/index.html")
+		if intact := strings.Contains(got, "$a *b* c$") && strings.Contains(got, "$$\nx *y* z\n$$"); intact != math {
+			t.Fatalf("Math=%t: build/math/index.html: got %q", math, got)
+		}
+		if has := strings.Contains(got, "katex"); has != math {
+			t.Fatalf("Math=%t: build/math/index.html: got %q, expected KaTeX only with math", math, got)
+		}
+		if plain := readFile(t, "build/plain/index.html"); strings.Contains(plain, "katex") {
+			t.Fatalf("Math=%t: build/plain/index.html: got %q, expected no KaTeX", math, plain)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"html/template"
+	"regexp"
+)
+
+// mermaidScript is the element that loads Mermaid. Layouts include it
+// with the "mermaidScript" function when Current.HasMermaid is true.
+const mermaidScript = `<script type="module">import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.esm.min.mjs"; mermaid.initialize({startOnLoad: true});</script>`
+
+var mermaidBlock = regexp.MustCompile(`(?s)<pre><code class="language-mermaid">(.*?)</code></pre>`)
+
+// renderMermaid replaces the "mermaid" fenced code blocks in content with
+// div elements of the class "mermaid", which Mermaid renders as
+// diagrams. It reports whether there were any such blocks.
+func renderMermaid(content template.HTML) (template.HTML, bool) {
+	if !mermaidBlock.MatchString(string(content)) {
+		return content, false
+	}
+	return template.HTML(mermaidBlock.ReplaceAllString(string(content), `<div class="mermaid">$1</div>`)), true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestRenderMermaid(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in       template.HTML
+		expected template.HTML
+		has      bool
+	}{
+		{
+			"<pre><code class=\"language-mermaid\">graph TD;\n  A--&gt;B;\n</code></pre>\n",
+			"<div class=\"mermaid\">graph TD;\n  A--&gt;B;\n</div>\n",
+			true,
+		},
+		{
+			"<pre><code class=\"language-mermaid\">a</code></pre>\n<p>x</p>\n<pre><code class=\"language-mermaid\">b</code></pre>",
+			"<div class=\"mermaid\">a</div>\n<p>x</p>\n<div class=\"mermaid\">b</div>",
+			true,
+		},
+		{
+			"<pre><code class=\"language-go\">x</code></pre>",
+			"<pre><code class=\"language-go\">x</code></pre>",
+			false,
+		},
+		{"<p>mermaid</p>", "<p>mermaid</p>", false},
+	}
+
+	for _, tc := range testcases {
+		got, has := renderMermaid(tc.in)
+		if got != tc.expected || has != tc.has {
+			t.Fatalf("renderMermaid %q: got %q, %t, expected %q, %t", tc.in, got, has, tc.expected, tc.has)
+		}
+	}
+}
+
+func TestBuildMermaid(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `{{ .Current.Content }}{{ if .Current.HasMermaid }}{{ mermaidScript }}{{ end }}`,
+		"src/diagram.md":  "```mermaid\ngraph TD;\n  A-->B;\n```\n",
+		"src/plain.md":    "```go\nx := 1\n```\n",
+	})
+	t.Chdir(dir)
+
+	for _, mermaid := range []bool{false, true} {
+		if err := (&Build{NoMinify: true, Mermaid: mermaid}).Run(); err != nil {
+			t.Fatal(err)
+		}
+		diagram := readFile(t, "build/diagram/index.html")
+		if got := strings.Contains(diagram, `<div class="mermaid">graph TD;`); got != mermaid {
+			t.Fatalf("Mermaid=%t: build/diagram/index.html: got %q", mermaid, diagram)
+		}
+		if got := strings.Count(diagram, "<script"); got != map[bool]int{false: 0, true: 1}[mermaid] {
+			t.Fatalf("Mermaid=%t: build/diagram/index.html: got %d scripts in %q", mermaid, got, diagram)
+		}
+		if plain := readFile(t, "build/plain/index.html"); strings.Contains(plain, "<script") {
+			t.Fatalf("Mermaid=%t: build/plain/index.html: got %q, expected no script", mermaid, plain)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+
+	// Stdout.
+	buf := bytes.Buffer{}
+	stdout.SetOutput(&buf)
+	defer stdout.SetOutput(os.Stdout)
+	if err := (&New{Title: "Hello", Draft: true}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	var fm FrontMatter
+	if err := fm.Parse(strings.NewReader(buf.String()), ""); err != nil {
+		t.Fatalf("stdout: %s", err)
+	}
+	if fm.Title != "Hello" || !fm.Draft {
+		t.Fatalf("stdout: got %+v, expected title %q and draft", fm, "Hello")
+	}
+
+	// File, including parent directories.
+	name := filepath.Join(dir, "src", "blog", "post.md")
+	if err := (&New{Title: "Post", Out: name}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	fm = FrontMatter{}
+	if err := fm.Parse(strings.NewReader(readFile(t, filepath.ToSlash(name))), ""); err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	if fm.Title != "Post" || fm.Draft {
+		t.Fatalf("%s: got %+v, expected title %q", name, fm, "Post")
+	}
+
+	// Title from the file name.
+	name2 := filepath.Join(dir, "my-first-post.md")
+	if err := (&New{Out: name2}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	fm = FrontMatter{}
+	if err := fm.Parse(strings.NewReader(readFile(t, filepath.ToSlash(name2))), ""); err != nil {
+		t.Fatalf("%s: %s", name2, err)
+	}
+	if fm.Title != "My First Post" {
+		t.Fatalf("%s: got title %q, expected %q", name2, fm.Title, "My First Post")
+	}
+
+	// Existing file.
+	if err := (&New{Title: "Other", Out: name}).Run(); err == nil {
+		t.Fatalf("%s: expected error for existing file", name)
+	}
+	if got := readFile(t, filepath.ToSlash(name)); !strings.Contains(got, `"Post"`) {
+		t.Fatalf("%s: got %q, expected not to be overwritten", name, got)
+	}
+}
+
+func TestNewArchetype(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeTree(t, dir, map[string]string{
+		"src/_archetypes/blog.md":    "+++\ntitle = \"{{ .Title }}\"\ntime = \"{{ .Date }}\"\ntags = [\"blog\"]\n+++\n\nWrite here.\n",
+		"src/_archetypes/default.md": "+++\ntitle = \"{{ .Title }}\"\ndraft = {{ .Draft }}\n+++\n",
+	})
+
+	testcases := []struct {
+		out      string
+		draft    bool
+		expected string // Substring of the new file.
+		tags     []string
+	}{
+		{filepath.Join(src, "blog", "hello-world.md"), false, "\nWrite here.\n", []string{"blog"}},
+		{filepath.Join(src, "blog", "2016", "nested.md"), false, "\nWrite here.\n", []string{"blog"}},
+		{filepath.Join(src, "notes", "note.md"), true, "draft = true\n", nil},
+		{filepath.Join(src, "top.md"), false, "draft = false\n", nil},
+	}
+
+	for _, tc := range testcases {
+		if err := (&New{Out: tc.out, Draft: tc.draft, Src: src}).Run(); err != nil {
+			t.Fatal(err)
+		}
+		got := readFile(t, filepath.ToSlash(tc.out))
+		if !strings.Contains(got, tc.expected) {
+			t.Fatalf("%s: got %q, expected it to contain %q", tc.out, got, tc.expected)
+		}
+		var fm FrontMatter
+		if err := fm.Parse(strings.NewReader(got), ""); err != nil {
+			t.Fatalf("%s: %s", tc.out, err)
+		}
+		expectedTitle := humanizeFilename(filepath.Base(tc.out))
+		if fm.Title != expectedTitle || fm.Draft != tc.draft || strings.Join(fm.Tags, ",") != strings.Join(tc.tags, ",") {
+			t.Fatalf("%s: got %+v, expected title %q, draft %t, tags %q", tc.out, fm, expectedTitle, tc.draft, tc.tags)
+		}
+	}
+
+	// Without archetypes, the file only has front matter.
+	name := filepath.Join(dir, "other", "blog", "post.md")
+	if err := (&New{Out: name, Src: filepath.Join(dir, "none")}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.ToSlash(name)); !strings.HasPrefix(got, FrontMatterSep) || strings.Contains(got, "Write here.") {
+		t.Fatalf("%s: got %q, expected only front matter", name, got)
+	}
+}
+
+func TestHumanizeFilename(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in, expected string
+	}{
+		{"my-first-post.md", "My First Post"},
+		{"my_first_post.markdown", "My First Post"},
+		{"my first post.md", "My First Post"},
+		{"--go-1.8_released.md", "Go 1.8 Released"},
+		{"émigré-notes.md", "Émigré Notes"},
+		{"README", "README"},
+	}
+
+	for _, tc := range testcases {
+		if got := humanizeFilename(tc.in); got != tc.expected {
+			t.Fatalf("humanizeFilename(%q): got %s, expected %s", tc.in, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// NotFoundFile is the name of the page, in the output directory, served
+// for missing paths.
+const NotFoundFile = "404.html"
+
+// notFound returns a handler that serves requests with h if the requested
+// path exists in dir. Otherwise it serves NotFoundFile from dir with a 404
+// status, or the default 404 response if NotFoundFile does not exist.
+func notFound(dir string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		page, err := ioutil.ReadFile(filepath.Join(dir, NotFoundFile))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(page)
+	})
+}
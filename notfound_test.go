@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotFound(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"404.html":        "<p>custom not found</p>",
+		"index.html":      "<p>home</p>",
+		"blog/index.html": "<p>blog</p>",
+	})
+	ts := httptest.NewServer(notFound(dir, http.FileServer(http.Dir(dir))))
+	defer ts.Close()
+
+	noCustom := t.TempDir()
+	tsDefault := httptest.NewServer(notFound(noCustom, http.FileServer(http.Dir(noCustom))))
+	defer tsDefault.Close()
+
+	testcases := []struct {
+		url      string
+		status   int
+		expected string // Substring of the body.
+	}{
+		{ts.URL + "/", http.StatusOK, "<p>home</p>"},
+		{ts.URL + "/blog/", http.StatusOK, "<p>blog</p>"},
+		{ts.URL + "/missing", http.StatusNotFound, "<p>custom not found</p>"},
+		{ts.URL + "/blog/missing/", http.StatusNotFound, "<p>custom not found</p>"},
+		{ts.URL + "/../../etc/passwd", http.StatusNotFound, "<p>custom not found</p>"},
+		{tsDefault.URL + "/missing", http.StatusNotFound, "404 page not found"},
+	}
+
+	for _, tc := range testcases {
+		resp, err := http.Get(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status || !strings.Contains(string(b), tc.expected) {
+			t.Fatalf("%s: got %d %q, expected %d %q", tc.url, resp.StatusCode, b, tc.status, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// oEmbedder fetches embed HTML from oEmbed providers. Responses are
+// cached, so that a URL embedded in several pages is fetched once.
+type oEmbedder struct {
+	Client *http.Client // If nil, pluginClient is used.
+
+	mx    sync.Mutex
+	cache map[string]template.HTML // Keyed by request URL.
+}
+
+// defaultOEmbed is used by the oEmbed template function.
+var defaultOEmbed = &oEmbedder{}
+
+// oEmbedResponse is the part of an oEmbed response that is used.
+type oEmbedResponse struct {
+	Type  string `json:"type"`
+	HTML  string `json:"html"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// Embed returns the embed HTML for the resource at rawurl from the
+// oEmbed provider endpoint, such as "https://vimeo.com/api/oembed.json".
+// A failed request results in an error, rather than an empty embed.
+func (o *oEmbedder) Embed(endpoint, rawurl string) (template.HTML, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("oEmbed: %s", err)
+	}
+	q := u.Query()
+	q.Set("url", rawurl)
+	q.Set("format", "json")
+	u.RawQuery = q.Encode()
+	key := u.String()
+
+	o.mx.Lock()
+	h, ok := o.cache[key]
+	o.mx.Unlock()
+	if ok {
+		return h, nil
+	}
+
+	client := o.Client
+	if client == nil {
+		client = pluginClient
+	}
+	resp, err := client.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("oEmbed %s: %s", rawurl, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oEmbed %s: %s responded %s", rawurl, endpoint, resp.Status)
+	}
+	var r oEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("oEmbed %s: invalid response: %s", rawurl, err)
+	}
+
+	switch {
+	case r.HTML != "":
+		h = template.HTML(r.HTML)
+	case r.Type == "photo" && r.URL != "":
+		h = template.HTML(fmt.Sprintf("<img src=\"%s\" alt=\"%s\">",
+			template.HTMLEscapeString(r.URL), template.HTMLEscapeString(r.Title)))
+	default:
+		return "", fmt.Errorf("oEmbed %s: response of type %q has no html", rawurl, r.Type)
+	}
+
+	o.mx.Lock()
+	if o.cache == nil {
+		o.cache = make(map[string]template.HTML)
+	}
+	o.cache[key] = h
+	o.mx.Unlock()
+	return h, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is an http.RoundTripper that calls the function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// stubClient returns a client that responds to every request with the
+// status and body, and counts the requests in n.
+func stubClient(status int, body string, n *int) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		*n++
+		return &http.Response{
+			StatusCode: status,
+			Status:     http.StatusText(status),
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})}
+}
+
+func TestOEmbed(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		status   int
+		body     string
+		expected template.HTML
+		err      bool
+	}{
+		{200, `{"type": "video", "html": "<iframe src=\"https://player.vimeo.com/video/1\"></iframe>"}`, `<iframe src="https://player.vimeo.com/video/1"></iframe>`, false},
+		{200, `{"type": "photo", "url": "https://x.com/a.png", "title": "A & B"}`, `<img src="https://x.com/a.png" alt="A &amp; B">`, false},
+		{200, `{"type": "link"}`, "", true},
+		{200, `not json`, "", true},
+		{404, `{"html": "<p>x</p>"}`, "", true},
+	}
+
+	for _, tc := range testcases {
+		n := 0
+		o := &oEmbedder{Client: stubClient(tc.status, tc.body, &n)}
+		got, err := o.Embed("https://vimeo.com/api/oembed.json", "https://vimeo.com/1")
+		if (err != nil) != tc.err {
+			t.Fatalf("Embed %s: got err %v, expected error %t", tc.body, err, tc.err)
+		}
+		if got != tc.expected {
+			t.Fatalf("Embed %s: got %s, expected %s", tc.body, got, tc.expected)
+		}
+		if tc.err {
+			continue
+		}
+		// The second call is served from the cache.
+		if _, err := o.Embed("https://vimeo.com/api/oembed.json", "https://vimeo.com/1"); err != nil || n != 1 {
+			t.Fatalf("Embed %s: got %d requests, err %v; expected 1 request", tc.body, n, err)
+		}
+	}
+}
+
+func TestOEmbedRequest(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	o := &oEmbedder{Client: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r.URL.String()
+		return nil, errors.New("network down")
+	})}}
+	_, err := o.Embed("https://example.com/oembed?maxwidth=600", "https://example.com/v/1?t=2")
+	if err == nil || !strings.Contains(err.Error(), "network down") {
+		t.Fatalf("Embed: got err %v, expected network error", err)
+	}
+	const expected = "https://example.com/oembed?format=json&maxwidth=600&url=https%3A%2F%2Fexample.com%2Fv%2F1%3Ft%3D2"
+	if got != expected {
+		t.Fatalf("Embed: got request %s, expected %s", got, expected)
+	}
+}
+
+func TestPluginClient(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			<-block
+		case "/user/123abc":
+			w.Write([]byte(`{"html": "<p>ok</p>"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	oldClient, oldGistURL := pluginClient, gistURL
+	defer func() {
+		pluginClient, gistURL, CheckGists = oldClient, oldGistURL, false
+	}()
+	pluginClient = &http.Client{Timeout: 50 * time.Millisecond}
+	gistURL = srv.URL
+
+	// oEmbed uses pluginClient and its timeout.
+	start := time.Now()
+	if _, err := (&oEmbedder{}).Embed(srv.URL+"/slow", "https://example.com"); err == nil {
+		t.Fatalf("Embed: expected timeout error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("Embed: took %s, expected the 50ms timeout", d)
+	}
+	if got, err := (&oEmbedder{}).Embed(srv.URL+"/user/123abc", "https://example.com"); err != nil || got != "<p>ok</p>" {
+		t.Fatalf("Embed: got %s, %v; expected <p>ok</p>", got, err)
+	}
+
+	gist := DefaultFuncs()["Gist"].(func(...interface{}) (template.HTML, error))
+	if _, err := gist("user/missing"); err != nil {
+		t.Fatalf("Gist: got %v, expected no check without CheckGists", err)
+	}
+	CheckGists = true
+	if _, err := gist("user/123abc"); err != nil {
+		t.Fatalf("Gist: got %v, expected existing gist", err)
+	}
+	if _, err := gist("user/missing", "a.go"); err == nil {
+		t.Fatalf("Gist: expected error for missing gist")
+	}
+}
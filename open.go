@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// startCommand starts the named program with args without waiting for
+// it to exit. It is a variable so that tests can replace it.
+var startCommand = func(name string, args ...string) error {
+	return exec.Command(name, args...).Start()
+}
+
+// browserCommand returns the program and arguments that open url in the
+// default browser on the operating system goos, such as "linux".
+func browserCommand(goos, url string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "open", []string{url}
+	case "windows":
+		// cmd treats "&" as a command separator.
+		return "cmd", []string{"/c", "start", "", strings.Replace(url, "&", "^&", -1)}
+	default:
+		return "xdg-open", []string{url}
+	}
+}
+
+// openBrowser opens url in the default browser on the operating system
+// goos.
+func openBrowser(goos, url string) error {
+	name, args := browserCommand(goos, url)
+	return startCommand(name, args...)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOpenBrowser(t *testing.T) {
+	var name string
+	var args []string
+	var err error
+	defer func(f func(string, ...string) error) { startCommand = f }(startCommand)
+	startCommand = func(n string, a ...string) error {
+		name, args = n, a
+		return err
+	}
+
+	testcases := []struct {
+		goos, url string
+		name      string
+		args      []string
+	}{
+		{"linux", "http://localhost:8080/", "xdg-open", []string{"http://localhost:8080/"}},
+		{"freebsd", "http://localhost:8080/", "xdg-open", []string{"http://localhost:8080/"}},
+		{"darwin", "https://127.0.0.1:1234/blog/", "open", []string{"https://127.0.0.1:1234/blog/"}},
+		{"windows", "http://localhost:8080/?a=1&b=2", "cmd", []string{"/c", "start", "", "http://localhost:8080/?a=1^&b=2"}},
+	}
+
+	for _, tc := range testcases {
+		if err := openBrowser(tc.goos, tc.url); err != nil {
+			t.Fatal(err)
+		}
+		if name != tc.name || !reflect.DeepEqual(args, tc.args) {
+			t.Fatalf("openBrowser(%q, %q): got %s %q, expected %s %q", tc.goos, tc.url, name, args, tc.name, tc.args)
+		}
+	}
+
+	err = errors.New("exec: not found")
+	if got := openBrowser("linux", "http://localhost:8080/"); got != err {
+		t.Fatalf("openBrowser: got error %v, expected %v", got, err)
+	}
+}
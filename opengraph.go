@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// openGraph returns Open Graph and Twitter card meta elements for p,
+// such as {{ openGraph .Current .BaseURL }}. The image is the "image"
+// front matter param. Tags whose values are empty are omitted; og:url
+// is omitted without a base URL, since it must be absolute.
+func openGraph(p *Page, baseURL string) template.HTML {
+	buf := bytes.Buffer{}
+	meta := func(attr, key, val string) {
+		if val != "" {
+			fmt.Fprintf(&buf, "<meta %s=\"%s\" content=\"%s\">\n", attr, key, template.HTMLEscapeString(val))
+		}
+	}
+
+	image := p.Params["image"]
+	if strings.HasPrefix(image, "/") {
+		image = joinURL(baseURL, image)
+	}
+	url := ""
+	if baseURL != "" {
+		url = joinURL(baseURL, p.Path)
+	}
+	card := "summary"
+	if image != "" {
+		card = "summary_large_image"
+	}
+
+	meta("property", "og:title", p.Title)
+	meta("property", "og:type", "article")
+	meta("property", "og:url", url)
+	meta("property", "og:description", p.Description)
+	meta("property", "og:image", image)
+	meta("name", "twitter:card", card)
+	return template.HTML(buf.String())
+}
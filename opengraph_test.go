@@ -0,0 +1,52 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestOpenGraph(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		page     *Page
+		baseURL  string
+		expected template.HTML
+	}{
+		{
+			&Page{Title: "Hello & bye", Description: "A post", Path: "/blog/hello", Params: map[string]string{"image": "/img/a.png"}},
+			"https://x.com/",
+			`<meta property="og:title" content="Hello &amp; bye">
+<meta property="og:type" content="article">
+<meta property="og:url" content="https://x.com/blog/hello">
+<meta property="og:description" content="A post">
+<meta property="og:image" content="https://x.com/img/a.png">
+<meta name="twitter:card" content="summary_large_image">
+`,
+		},
+		{
+			&Page{Title: "Hello", Path: "/blog/hello"},
+			"https://x.com",
+			`<meta property="og:title" content="Hello">
+<meta property="og:type" content="article">
+<meta property="og:url" content="https://x.com/blog/hello">
+<meta name="twitter:card" content="summary">
+`,
+		},
+		{
+			&Page{Title: "Hello", Path: "/blog/hello", Params: map[string]string{"image": "https://cdn.x.com/a.png"}},
+			"",
+			`<meta property="og:title" content="Hello">
+<meta property="og:type" content="article">
+<meta property="og:image" content="https://cdn.x.com/a.png">
+<meta name="twitter:card" content="summary_large_image">
+`,
+		},
+	}
+
+	for _, tc := range testcases {
+		if got := openGraph(tc.page, tc.baseURL); got != tc.expected {
+			t.Fatalf("openGraph(%+v, %q): got %s, expected %s", tc.page, tc.baseURL, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// byTag returns the pages in all that have tag, in reverse
+// chronological order.
+func byTag(all map[string][]*Page, tag string) []*Page {
+	var ret []*Page
+	for _, pages := range all {
+		for _, p := range pages {
+			for _, t := range p.Tags {
+				if t == tag {
+					ret = append(ret, p)
+					break
+				}
+			}
+		}
+	}
+	sort.Sort(ByTime(ret))
+	return ret
+}
+
+// pagesIn returns the pages in all for dir, a slash-separated path
+// relative to the source directory, such as "blog" or "/blog/". The
+// root directory is "" or "/". Unknown directories result in an empty
+// slice.
+func pagesIn(all map[string][]*Page, dir string) []*Page {
+	key := filepath.FromSlash(path.Clean("/" + strings.Trim(dir, "/"))[1:])
+	if key == "" {
+		key = "."
+	}
+	if pages, ok := all[key]; ok {
+		return pages
+	}
+	return []*Page{}
+}
+
+// where returns the pages whose field, such as "Title" or "Draft", equals
+// value. For slice fields, such as "Tags", pages whose field contains
+// value are returned. The order of pages is preserved.
+func where(pages []*Page, field string, value interface{}) ([]*Page, error) {
+	f, ok := reflect.TypeOf(Page{}).FieldByName(field)
+	if !ok {
+		return nil, fmt.Errorf("where: Page has no field %q", field)
+	}
+
+	var ret []*Page
+	for _, p := range pages {
+		v := reflect.ValueOf(p).Elem().FieldByIndex(f.Index)
+		if v.Kind() == reflect.Slice {
+			for i := 0; i < v.Len(); i++ {
+				if v.Index(i).Interface() == value {
+					ret = append(ret, p)
+					break
+				}
+			}
+			continue
+		}
+		if v.Type().Comparable() && v.Interface() == value {
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
+
+// relatedPages returns up to n pages in all, other than cur and drafts,
+// that share at least one tag with cur. Pages sharing more tags come
+// first; ties are in reverse chronological order.
+func relatedPages(cur *Page, all []*Page, n int) []*Page {
+	tags := make(map[string]bool, len(cur.Tags))
+	for _, t := range cur.Tags {
+		tags[t] = true
+	}
+
+	var ret []*Page
+	score := make(map[*Page]int)
+	for _, p := range all {
+		if p == cur || p.Draft {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, t := range p.Tags {
+			if tags[t] && !seen[t] {
+				seen[t] = true
+				score[p]++
+			}
+		}
+		if score[p] > 0 {
+			ret = append(ret, p)
+		}
+	}
+
+	sort.Sort(ByTime(ret))
+	sort.SliceStable(ret, func(i, j int) bool { return score[ret[i]] > score[ret[j]] })
+	if n >= 0 && len(ret) > n {
+		ret = ret[:n]
+	}
+	return ret
+}
+
+// recentPages returns the non-draft pages in all, in reverse
+// chronological order.
+func recentPages(all []*Page) []*Page {
+	var ret []*Page
+	for _, p := range all {
+		if !p.Draft {
+			ret = append(ret, p)
+		}
+	}
+	sort.Sort(ByTime(ret))
+	return ret
+}
+
+// first returns the first n pages, or all pages if there are fewer
+// than n.
+func first(n int, pages []*Page) []*Page {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(pages) {
+		n = len(pages)
+	}
+	return pages[:n]
+}
+
+// YearGroup is the pages published in a year, as returned by groupByYear.
+type YearGroup struct {
+	Year  int
+	Pages []*Page
+}
+
+// MonthGroup is the pages published in a month, as returned by
+// groupByMonth.
+type MonthGroup struct {
+	Year  int
+	Month time.Month
+	Pages []*Page
+}
+
+// groupByYear groups pages by the year of their Time. Groups are in
+// reverse chronological order, as are the pages in each group.
+func groupByYear(pages []*Page) []YearGroup {
+	var groups []YearGroup
+	index := make(map[int]int) // Index in groups of each year.
+	for _, p := range sortedByTime(pages) {
+		y := p.Time.Year()
+		i, ok := index[y]
+		if !ok {
+			i = len(groups)
+			index[y] = i
+			groups = append(groups, YearGroup{Year: y})
+		}
+		groups[i].Pages = append(groups[i].Pages, p)
+	}
+	// Pages in other time zones may be out of order by their local year.
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Year > groups[j].Year })
+	return groups
+}
+
+// groupByMonth is like groupByYear, but groups pages by the year and
+// month of their Time.
+func groupByMonth(pages []*Page) []MonthGroup {
+	type key struct {
+		year  int
+		month time.Month
+	}
+	var groups []MonthGroup
+	index := make(map[key]int) // Index in groups of each month.
+	for _, p := range sortedByTime(pages) {
+		k := key{p.Time.Year(), p.Time.Month()}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, MonthGroup{Year: k.year, Month: k.month})
+		}
+		groups[i].Pages = append(groups[i].Pages, p)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Year != groups[j].Year {
+			return groups[i].Year > groups[j].Year
+		}
+		return groups[i].Month > groups[j].Month
+	})
+	return groups
+}
+
+// sortedByTime returns a copy of pages sorted by ByTime.
+func sortedByTime(pages []*Page) []*Page {
+	ret := append([]*Page(nil), pages...)
+	sort.Sort(ByTime(ret))
+	return ret
+}
+
+// flatten returns the pages in all directories, in no particular order.
+func flatten(all map[string][]*Page) []*Page {
+	var ret []*Page
+	for _, pages := range all {
+		ret = append(ret, pages...)
+	}
+	return ret
+}
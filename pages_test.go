@@ -0,0 +1,242 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func titles(pages []*Page) []string {
+	ret := make([]string, len(pages))
+	for i, p := range pages {
+		ret[i] = p.Title
+	}
+	return ret
+}
+
+func TestByTag(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2016, time.January, d, 0, 0, 0, 0, time.UTC) }
+	all := map[string][]*Page{
+		".":    {{Title: "a", Time: day(1), Tags: []string{"go"}}, {Title: "b", Time: day(5)}},
+		"blog": {{Title: "c", Time: day(3), Tags: []string{"rust", "go"}}, {Title: "d", Time: day(4), Tags: []string{"rust"}}},
+		"misc": {{Title: "e", Time: day(2), Tags: []string{"go", "go"}}},
+	}
+
+	testcases := []struct {
+		tag      string
+		expected []string
+	}{
+		{"go", []string{"c", "e", "a"}},
+		{"rust", []string{"d", "c"}},
+		{"none", []string{}},
+	}
+
+	for _, tc := range testcases {
+		got := titles(byTag(all, tc.tag))
+		if len(got) != len(tc.expected) {
+			t.Fatalf("byTag %q: got %v, expected %v", tc.tag, got, tc.expected)
+		}
+		for i := range got {
+			if got[i] != tc.expected[i] {
+				t.Fatalf("byTag %q: got %v, expected %v", tc.tag, got, tc.expected)
+			}
+		}
+	}
+}
+
+func TestPagesIn(t *testing.T) {
+	t.Parallel()
+
+	all := map[string][]*Page{
+		".":                           {{Title: "a"}},
+		"blog":                        {{Title: "b"}, {Title: "c"}},
+		filepath.Join("blog", "2016"): {{Title: "d"}},
+	}
+
+	testcases := []struct {
+		dir      string
+		expected []string
+	}{
+		{"blog", []string{"b", "c"}},
+		{"/blog/", []string{"b", "c"}},
+		{"blog//", []string{"b", "c"}},
+		{"blog/2016/", []string{"d"}},
+		{"", []string{"a"}},
+		{"/", []string{"a"}},
+		{"missing", []string{}},
+	}
+
+	for _, tc := range testcases {
+		pages := pagesIn(all, tc.dir)
+		if pages == nil {
+			t.Fatalf("pagesIn %q: got nil, expected non-nil slice", tc.dir)
+		}
+		if got := titles(pages); !reflect.DeepEqual(got, tc.expected) {
+			t.Fatalf("pagesIn %q: got %v, expected %v", tc.dir, got, tc.expected)
+		}
+	}
+}
+
+func TestWhere(t *testing.T) {
+	t.Parallel()
+
+	pages := []*Page{
+		{Title: "a", Tags: []string{"go"}},
+		{Title: "b", Draft: true},
+		{Title: "c", Tags: []string{"go"}, Draft: true},
+	}
+
+	testcases := []struct {
+		field    string
+		value    interface{}
+		expected []string
+	}{
+		{"Draft", true, []string{"b", "c"}},
+		{"Title", "a", []string{"a"}},
+		{"Tags", "go", []string{"a", "c"}},
+	}
+
+	for _, tc := range testcases {
+		res, err := where(pages, tc.field, tc.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := titles(res)
+		if len(got) != len(tc.expected) {
+			t.Fatalf("where %s %v: got %v, expected %v", tc.field, tc.value, got, tc.expected)
+		}
+		for i := range got {
+			if got[i] != tc.expected[i] {
+				t.Fatalf("where %s %v: got %v, expected %v", tc.field, tc.value, got, tc.expected)
+			}
+		}
+	}
+
+	if _, err := where(pages, "Nope", 1); err == nil {
+		t.Fatal("where Nope: expected error")
+	}
+}
+
+func TestRelatedPages(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2016, time.January, d, 0, 0, 0, 0, time.UTC) }
+	cur := &Page{Title: "cur", Time: day(9), Tags: []string{"go", "web", "tools"}}
+	all := []*Page{
+		cur,
+		{Title: "a", Time: day(1), Tags: []string{"go", "web", "tools"}},
+		{Title: "b", Time: day(2), Tags: []string{"go"}},
+		{Title: "c", Time: day(3), Tags: []string{"web", "go", "go"}},
+		{Title: "d", Time: day(4), Tags: []string{"go", "web"}},
+		{Title: "e", Time: day(5), Tags: []string{"rust"}},
+		{Title: "f", Time: day(6), Tags: []string{"go", "web", "tools"}, Draft: true},
+		{Title: "g", Time: day(7), Tags: []string{"tools"}},
+	}
+
+	testcases := []struct {
+		n        int
+		expected []string
+	}{
+		{-1, []string{"a", "d", "c", "g", "b"}},
+		{3, []string{"a", "d", "c"}},
+		{0, []string{}},
+	}
+
+	for _, tc := range testcases {
+		got := titles(relatedPages(cur, all, tc.n))
+		if len(got) != len(tc.expected) {
+			t.Fatalf("relatedPages %d: got %v, expected %v", tc.n, got, tc.expected)
+		}
+		for i := range got {
+			if got[i] != tc.expected[i] {
+				t.Fatalf("relatedPages %d: got %v, expected %v", tc.n, got, tc.expected)
+			}
+		}
+	}
+}
+
+func TestFirst(t *testing.T) {
+	t.Parallel()
+
+	pages := []*Page{{Title: "a"}, {Title: "b"}, {Title: "c"}}
+
+	testcases := []struct {
+		n        int
+		expected []string
+	}{
+		{2, []string{"a", "b"}},
+		{3, []string{"a", "b", "c"}},
+		{10, []string{"a", "b", "c"}},
+		{0, []string{}},
+		{-1, []string{}},
+	}
+
+	for _, tc := range testcases {
+		got := titles(first(tc.n, pages))
+		if len(got) != len(tc.expected) {
+			t.Fatalf("first %d: got %v, expected %v", tc.n, got, tc.expected)
+		}
+		for i := range got {
+			if got[i] != tc.expected[i] {
+				t.Fatalf("first %d: got %v, expected %v", tc.n, got, tc.expected)
+			}
+		}
+	}
+	if got := first(5, nil); len(got) != 0 {
+		t.Fatalf("first 5 nil: got %v, expected empty", titles(got))
+	}
+}
+
+func TestGroupByYearMonth(t *testing.T) {
+	t.Parallel()
+
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	pages := []*Page{
+		{Title: "a", Time: date(2015, time.March, 1)},
+		{Title: "b", Time: date(2016, time.January, 5)},
+		{Title: "c", Time: date(2015, time.December, 31)},
+		{Title: "d", Time: date(2016, time.January, 20)},
+		{Title: "e", Time: date(2015, time.March, 15)},
+		{Title: "f", Time: date(2017, time.June, 1)},
+	}
+
+	type group struct {
+		year   int
+		month  time.Month // Zero for groupByYear.
+		titles []string
+	}
+
+	var got []group
+	for _, g := range groupByYear(pages) {
+		got = append(got, group{g.Year, 0, titles(g.Pages)})
+	}
+	expected := []group{
+		{2017, 0, []string{"f"}},
+		{2016, 0, []string{"d", "b"}},
+		{2015, 0, []string{"c", "e", "a"}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("groupByYear: got %v, expected %v", got, expected)
+	}
+
+	got = nil
+	for _, g := range groupByMonth(pages) {
+		got = append(got, group{g.Year, g.Month, titles(g.Pages)})
+	}
+	expected = []group{
+		{2017, time.June, []string{"f"}},
+		{2016, time.January, []string{"d", "b"}},
+		{2015, time.December, []string{"c"}},
+		{2015, time.March, []string{"e", "a"}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("groupByMonth: got %v, expected %v", got, expected)
+	}
+
+	if got := groupByYear(nil); len(got) != 0 {
+		t.Fatalf("groupByYear nil: got %v, expected no groups", got)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path"
+	"strconv"
+)
+
+// Paginator is a page of a directory's markdown pages, available to
+// "index.html" templates.
+type Paginator struct {
+	Pages      []*Page // Markdown pages in this page.
+	PageNum    int     // Page number, starting at 1.
+	TotalPages int     // Total number of pages.
+	PrevURL    string  // URL of the previous page, or "" if PageNum is 1.
+	NextURL    string  // URL of the next page, or "" if PageNum is TotalPages.
+}
+
+// paginate splits pages into Paginators of size pages each. The first
+// Paginator lives at dirURL and page n at dirURL/page/n/. If size is not
+// positive, a single Paginator with all pages is returned.
+func paginate(pages []*Page, size int, dirURL string) []*Paginator {
+	if size <= 0 {
+		size = len(pages)
+	}
+	total := 1
+	if size > 0 && len(pages) > size {
+		total = (len(pages) + size - 1) / size
+	}
+
+	ret := make([]*Paginator, total)
+	for i := range ret {
+		start, end := i*size, (i+1)*size
+		if end > len(pages) {
+			end = len(pages)
+		}
+		ret[i] = &Paginator{
+			Pages:      pages[start:end],
+			PageNum:    i + 1,
+			TotalPages: total,
+		}
+		if i > 0 {
+			ret[i].PrevURL = pageURL(dirURL, i)
+		}
+		if i < total-1 {
+			ret[i].NextURL = pageURL(dirURL, i+2)
+		}
+	}
+	return ret
+}
+
+// pageURL returns the URL of page number n of the directory at dirURL.
+func pageURL(dirURL string, n int) string {
+	u := path.Clean("/" + dirURL)
+	if n > 1 {
+		u = path.Join(u, "page", strconv.Itoa(n))
+	}
+	if u == "/" {
+		return u
+	}
+	return u + "/"
+}
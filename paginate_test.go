@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	t.Parallel()
+
+	makePages := func(n int) []*Page {
+		pages := make([]*Page, n)
+		for i := range pages {
+			pages[i] = &Page{Title: fmt.Sprint(i)}
+		}
+		return pages
+	}
+
+	type expected struct {
+		n                int // Number of pages.
+		prevURL, nextURL string
+	}
+	testcases := []struct {
+		pages    int
+		size     int
+		dirURL   string
+		expected []expected
+	}{
+		{20, 10, "blog", []expected{
+			{10, "", "/blog/page/2/"},
+			{10, "/blog/", ""},
+		}},
+		{25, 10, "blog", []expected{
+			{10, "", "/blog/page/2/"},
+			{10, "/blog/", "/blog/page/3/"},
+			{5, "/blog/page/2/", ""},
+		}},
+		{25, 0, "blog", []expected{
+			{25, "", ""},
+		}},
+		{0, 10, ".", []expected{
+			{0, "", ""},
+		}},
+		{3, 2, ".", []expected{
+			{2, "", "/page/2/"},
+			{1, "/", ""},
+		}},
+	}
+
+	for _, tc := range testcases {
+		pages := makePages(tc.pages)
+		res := paginate(pages, tc.size, tc.dirURL)
+		if len(res) != len(tc.expected) {
+			t.Fatalf("paginate(%d, %d): got %d pages, expected %d", tc.pages, tc.size, len(res), len(tc.expected))
+		}
+		seen := 0
+		for i, e := range tc.expected {
+			pg := res[i]
+			if len(pg.Pages) != e.n || pg.PageNum != i+1 || pg.TotalPages != len(tc.expected) ||
+				pg.PrevURL != e.prevURL || pg.NextURL != e.nextURL {
+				t.Fatalf("paginate(%d, %d): page %d: got %d pages, %d of %d, prev %q, next %q, expected %d pages, %d of %d, prev %q, next %q",
+					tc.pages, tc.size, i+1,
+					len(pg.Pages), pg.PageNum, pg.TotalPages, pg.PrevURL, pg.NextURL,
+					e.n, i+1, len(tc.expected), e.prevURL, e.nextURL)
+			}
+			for _, p := range pg.Pages {
+				if p != pages[seen] {
+					t.Fatalf("paginate(%d, %d): page %d: got %q, expected %q", tc.pages, tc.size, i+1, p.Title, pages[seen].Title)
+				}
+				seen++
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+)
+
+// PartialsDir is the directory, relative to the source directory, of
+// partial templates. The ".tmpl" files in it are available to all
+// layout.tmpl and .html templates, for example {{ template "header" . }}.
+const PartialsDir = "_partials"
+
+// LayoutsDir is the directory, relative to the source directory, of
+// layouts that markdown files select by name with the "layout" front
+// matter key, for example layout = "wide.tmpl".
+const LayoutsDir = "_layouts"
+
+// loadPartials parses the ".tmpl" files in dir into a template set with
+// funcs. A missing or empty dir results in an empty set.
+func loadPartials(dir string, funcs template.FuncMap) (*template.Template, error) {
+	t := template.New("").Funcs(funcs)
+	files, err := partialFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return t, nil
+	}
+	return t.ParseFiles(files...)
+}
+
+// partialFiles returns the ".tmpl" files in dir.
+func partialFiles(dir string) ([]string, error) {
+	return filepath.Glob(filepath.Join(dir, "*.tmpl"))
+}
+
+// parseTemplate parses the named files, in order, into a copy of
+// partials and returns the template for the last file. partials itself
+// is not modified, so it can be shared by concurrent calls.
+//
+// Files with the same base name define the same template. A later file
+// whose body outside of {{ define }} actions is empty does not replace
+// the body of an earlier one; it only overrides the blocks it defines.
+func parseTemplate(partials *template.Template, files ...string) (*template.Template, error) {
+	t, err := partials.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if t, err = t.ParseFiles(files...); err != nil {
+		return nil, err
+	}
+	return t.Lookup(filepath.Base(files[len(files)-1])), nil
+}
+
+// layoutName returns the name of the layout file for pages with the
+// output extension ext: "layout.tmpl" for HTML, where ext is empty, and
+// "layout.<ext>.tmpl" otherwise.
+func layoutName(ext string) string {
+	if ext == "" {
+		return "layout.tmpl"
+	}
+	return "layout." + ext + ".tmpl"
+}
+
+// isLayout reports whether name is the base name of a layout file.
+func isLayout(name string) bool {
+	return strings.HasPrefix(name, "layout.") && strings.HasSuffix(name, ".tmpl")
+}
+
+// namedLayoutFile returns the layout file named name for a markdown file
+// in dir: the file in dir, or else the file in the LayoutsDir of root.
+// It is an error if neither exists.
+func namedLayoutFile(root, dir, name string) (string, error) {
+	for _, file := range []string{filepath.Join(dir, name), filepath.Join(root, LayoutsDir, name)} {
+		exists, err := pathExists(file)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("missing layout %q in %q or %q", name, dir, filepath.Join(root, LayoutsDir))
+}
+
+// layoutFiles returns the files with the given base name, such as
+// "layout.tmpl", in dir and its parent directories up to and including
+// root, ordered from root to dir.
+func layoutFiles(root, dir, name string) ([]string, error) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for {
+		file := filepath.Join(root, rel, name)
+		exists, err := pathExists(file)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			files = append([]string{file}, files...)
+		}
+		if rel == "." {
+			return files, nil
+		}
+		rel = filepath.Dir(rel)
+	}
+}
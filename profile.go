@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// A phase is a part of a build that is timed with -profile.
+type phase int
+
+const (
+	phaseMakePages phase = iota // Reading markdown files and their front matter.
+	phaseRender                 // Rendering markdown to HTML.
+	phaseMinify                 // Minifying HTML, CSS, JS, and SVG.
+	phaseExecute                // Executing templates.
+	phaseWrite                  // Writing files to the build directory.
+	numPhases
+)
+
+var phaseNames = [numPhases]string{"makePages", "render", "minify", "execute", "write"}
+
+// buildStats holds the counts and total durations of the phases of a
+// build, and the number of pages and bytes written. It is updated
+// concurrently by the build's goroutines. The methods of a nil
+// *buildStats do nothing, so builds without -profile are not slowed.
+type buildStats struct {
+	count [numPhases]int64
+	nanos [numPhases]int64
+	pages int64 // Markdown pages rendered.
+	bytes int64 // Bytes written.
+}
+
+// since records an operation in phase ph that began at start.
+func (s *buildStats) since(ph phase, start time.Time) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.count[ph], 1)
+	atomic.AddInt64(&s.nanos[ph], int64(time.Since(start)))
+}
+
+func (s *buildStats) addPage() {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.pages, 1)
+}
+
+func (s *buildStats) addBytes(n int) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.bytes, int64(n))
+}
+
+// print writes a table of the stats to w. Durations of phases that run
+// concurrently are summed, so they may exceed the total build time.
+func (s *buildStats) print(w io.Writer, total time.Duration) {
+	fmt.Fprintf(w, "%-10s %8s %12s\n", "phase", "count", "time")
+	for ph := phase(0); ph < numPhases; ph++ {
+		d := time.Duration(atomic.LoadInt64(&s.nanos[ph]))
+		fmt.Fprintf(w, "%-10s %8d %12s\n", phaseNames[ph], atomic.LoadInt64(&s.count[ph]), d.Round(time.Microsecond))
+	}
+	fmt.Fprintf(w, "%d pages, %d bytes written in %s\n", atomic.LoadInt64(&s.pages), atomic.LoadInt64(&s.bytes), total.Round(time.Microsecond))
+}
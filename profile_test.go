@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBuildProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl": `<p>{{ .Current.Content }}</p>`,
+		"src/a.md":        "a",
+		"src/b.md":        "b",
+		"src/index.html":  `<p>{{ len .Dir }}</p>`,
+		"src/style.css":   "a { color: red; }",
+		"src/robots.txt":  "",
+	})
+	t.Chdir(dir)
+
+	var buf bytes.Buffer
+	stderr.SetOutput(&buf)
+	defer stderr.SetOutput(os.Stderr)
+
+	b := &Build{Profile: true}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	s := b.stats
+	if s.pages != 2 {
+		t.Fatalf("pages: got %d, expected 2", s.pages)
+	}
+	if s.bytes == 0 {
+		t.Fatalf("bytes: got 0, expected non-zero")
+	}
+	expected := [numPhases]int64{
+		phaseMakePages: 1,
+		phaseRender:    2,
+		phaseMinify:    4, // Three HTML files and a CSS file.
+		phaseExecute:   3,
+		phaseWrite:     5,
+	}
+	if s.count != expected {
+		t.Fatalf("counts: got %v, expected %v", s.count, expected)
+	}
+	for _, name := range phaseNames {
+		if !strings.Contains(buf.String(), name) {
+			t.Fatalf("output: got %q, expected it to contain %q", buf.String(), name)
+		}
+	}
+
+	// Without Profile, nothing is recorded or printed.
+	buf.Reset()
+	b = &Build{}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if b.stats != nil || buf.Len() != 0 {
+		t.Fatalf("Profile=false: got stats %v and output %q", b.stats, buf.String())
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// readSrcFile returns the contents of the file name, relative to the
+// source directory src. Names that refer outside src, including through
+// symlinks, are an error.
+func readSrcFile(src, name string) (string, error) {
+	p, err := srcPath(src, name)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// srcPath returns the path of the file name, relative to src, after
+// checking that it is inside src.
+func srcPath(src, name string) (string, error) {
+	outside := fmt.Errorf("readFile: %q is outside the source directory", name)
+	if filepath.IsAbs(name) || escapes(filepath.Clean(filepath.FromSlash(name))) {
+		return "", outside
+	}
+	root, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return "", err
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, p); err != nil || escapes(rel) {
+		return "", outside
+	}
+	return p, nil
+}
+
+// escapes returns whether the relative path rel refers to its parent
+// directory or above.
+func escapes(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// highlightFile returns the contents of the file name, relative to src,
+// in a pre element. Go files are highlighted with span elements of the
+// classes "keyword", "string", "number", and "comment". Other files are
+// escaped but not highlighted.
+func highlightFile(src, name string) (template.HTML, error) {
+	s, err := readSrcFile(src, name)
+	if err != nil {
+		return "", err
+	}
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+
+	buf := bytes.Buffer{}
+	buf.WriteString(`<pre><code class="language-` + template.HTMLEscapeString(ext) + `">`)
+	if ext == "go" {
+		highlightGo(&buf, s)
+	} else {
+		template.HTMLEscape(&buf, []byte(s))
+	}
+	buf.WriteString("</code></pre>")
+	return template.HTML(buf.String()), nil
+}
+
+// highlightGo writes the Go source s, escaped and highlighted, to buf.
+func highlightGo(buf *bytes.Buffer, s string) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(s))
+	var sc scanner.Scanner
+	sc.Init(file, []byte(s), func(token.Position, string) {}, scanner.ScanComments)
+
+	last := 0
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		class, n := "", len(lit)
+		switch {
+		case tok.IsKeyword():
+			class, n = "keyword", len(tok.String())
+		case tok == token.STRING, tok == token.CHAR:
+			class = "string"
+		case tok == token.INT, tok == token.FLOAT, tok == token.IMAG:
+			class = "number"
+		case tok == token.COMMENT:
+			class = "comment"
+		}
+		start := file.Offset(pos)
+		if class == "" || start < last || start+n > len(s) {
+			continue
+		}
+		template.HTMLEscape(buf, []byte(s[last:start]))
+		buf.WriteString(`<span class="` + class + `">`)
+		template.HTMLEscape(buf, []byte(s[start:start+n]))
+		buf.WriteString(`</span>`)
+		last = start + n
+	}
+	template.HTMLEscape(buf, []byte(s[last:]))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadSrcFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/LICENSE":     "MIT",
+		"src/code/a.txt":  "a",
+		"secret.txt":      "secret",
+		"src/code/b.html": "<b>",
+	})
+	src := filepath.Join(dir, "src")
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(src, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name     string
+		expected string
+		err      bool
+	}{
+		{"LICENSE", "MIT", false},
+		{"code/a.txt", "a", false},
+		{"/code/a.txt", "", true},
+		{"code/../LICENSE", "MIT", false},
+		{"../secret.txt", "", true},
+		{"../../etc/passwd", "", true},
+		{"link.txt", "", true},
+		{"missing", "", true},
+	}
+
+	for _, tc := range testcases {
+		got, err := readSrcFile(src, tc.name)
+		if (err != nil) != tc.err {
+			t.Fatalf("readSrcFile(%q): got error %v, expected error %t", tc.name, err, tc.err)
+		}
+		if got != tc.expected {
+			t.Fatalf("readSrcFile(%q): got %q, expected %q", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestHighlightFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"main.go":  "package main\n\n// Hi.\nfunc main() { println(\"<hi>\", 42) }\n",
+		"page.txt": "a < b",
+	})
+
+	testcases := []struct {
+		name     string
+		expected string
+	}{
+		{
+			"main.go",
+			`<pre><code class="language-go"><span class="keyword">package</span> main` + "\n\n" +
+				`<span class="comment">// Hi.</span>` + "\n" +
+				`<span class="keyword">func</span> main() { println(<span class="string">&#34;&lt;hi&gt;&#34;</span>, <span class="number">42</span>) }` + "\n" +
+				`</code></pre>`,
+		},
+		{"page.txt", `<pre><code class="language-txt">a &lt; b</code></pre>`},
+	}
+
+	for _, tc := range testcases {
+		got, err := highlightFile(dir, tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.expected {
+			t.Fatalf("highlightFile(%q): got %s, expected %s", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildReadFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html":     `<pre>{{ readFile "snippets/a.txt" }}</pre>`,
+		"src/snippets/a.txt": "x < y",
+		"src/bad.html":       `{{ readFile "../../etc/passwd" }}`,
+	})
+	t.Chdir(dir)
+
+	err := (&Build{}).Run()
+	if err == nil || !strings.Contains(err.Error(), "outside the source directory") {
+		t.Fatalf("Run: got error %v, expected outside the source directory", err)
+	}
+	os.Remove(filepath.Join(dir, "src", "bad.html"))
+	if err := (&Build{}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := readFile(t, "build/index.html"), "<pre>x &lt; y</pre>"; got != expected {
+		t.Fatalf("build/index.html: got %q, expected %q", got, expected)
+	}
+}
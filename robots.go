@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// RobotsFile is the name of the robots.txt file.
+const RobotsFile = "robots.txt"
+
+// robotsTxt returns a robots.txt that allows all crawlers. If sitemapURL
+// is non-empty, it points to the sitemap at sitemapURL.
+func robotsTxt(sitemapURL string) string {
+	s := "User-agent: *\nAllow: /\n"
+	if sitemapURL != "" {
+		s += "Sitemap: " + sitemapURL + "\n"
+	}
+	return s
+}
+
+// writeRobots writes the default robots.txt to the build directory,
+// unless the source directory has its own, which is copied as usual.
+// If baseURL is non-empty, it points to the sitemap when one is
+// generated, as indicated by sitemap, or the source directory has a
+// SitemapFile.
+func writeRobots(src, build, baseURL string, sitemap bool) error {
+	if _, err := os.Stat(filepath.Join(src, RobotsFile)); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	sitemapURL := ""
+	if baseURL != "" && !sitemap {
+		ok, err := pathExists(filepath.Join(src, SitemapFile))
+		if err != nil {
+			return err
+		}
+		sitemap = ok
+	}
+	if baseURL != "" && sitemap {
+		sitemapURL = joinURL(baseURL, "/"+SitemapFile)
+	}
+	_, err := writeIfChanged(filepath.Join(build, RobotsFile), []byte(robotsTxt(sitemapURL)))
+	return err
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestBuildRobots(t *testing.T) {
+	testcases := []struct {
+		files    map[string]string
+		baseURL  string
+		sitemap  bool
+		expected string
+	}{
+		{
+			map[string]string{"src/index.html": ""},
+			"https://example.com/",
+			true,
+			"User-agent: *\nAllow: /\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			map[string]string{"src/index.html": "", "src/sitemap.xml": "<urlset/>"},
+			"https://example.com/",
+			false,
+			"User-agent: *\nAllow: /\nSitemap: https://example.com/sitemap.xml\n",
+		},
+		{
+			// No sitemap, so robots.txt does not point to a missing one.
+			map[string]string{"src/index.html": ""},
+			"https://example.com/",
+			false,
+			"User-agent: *\nAllow: /\n",
+		},
+		{
+			map[string]string{"src/index.html": "", "src/sitemap.xml": "<urlset/>"},
+			"",
+			false,
+			"User-agent: *\nAllow: /\n",
+		},
+		{
+			map[string]string{"src/robots.txt": "User-agent: *\nDisallow: /\n"},
+			"https://example.com",
+			true,
+			"User-agent: *\nDisallow: /\n",
+		},
+	}
+
+	for _, tc := range testcases {
+		dir := t.TempDir()
+		writeTree(t, dir, tc.files)
+		t.Chdir(dir)
+
+		if err := (&Build{Robots: true, Sitemap: tc.sitemap, BaseURL: tc.baseURL}).Run(); err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, "build/robots.txt"); got != tc.expected {
+			t.Fatalf("build/robots.txt: got %q, expected %q", got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// scssExts is the extensions considered to be SCSS files. Files whose
+// names begin with "_" are partials, which are only imported. Files
+// with the indented ".sass" syntax are reported as errors, since only
+// the SCSS syntax is supported.
+var scssExts = map[string]bool{
+	".scss": true,
+	".sass": true,
+}
+
+var (
+	scssImport  = regexp.MustCompile(`(?m)^[ \t]*@import[ \t]+(?:"([^"]+)"|'([^']+)')[ \t]*;[ \t]*\n?`)
+	scssVarDecl = regexp.MustCompile(`(?m)^[ \t]*\$([\w-]+)[ \t]*:[ \t]*([^;]*?)[ \t]*(!default)?[ \t]*;[ \t]*\n?`)
+	scssVarUse  = regexp.MustCompile(`\$([\w-]+)`)
+	scssAtRule  = regexp.MustCompile(`@[\w-]+`)
+)
+
+// scssUnsupported is the SCSS at-rules that compileSCSS reports as
+// errors.
+var scssUnsupported = map[string]bool{
+	"@mixin":    true,
+	"@include":  true,
+	"@content":  true,
+	"@extend":   true,
+	"@function": true,
+	"@return":   true,
+	"@if":       true,
+	"@else":     true,
+	"@each":     true,
+	"@for":      true,
+	"@while":    true,
+	"@use":      true,
+	"@forward":  true,
+	"@at-root":  true,
+	"@debug":    true,
+	"@warn":     true,
+	"@error":    true,
+}
+
+// compileSCSS compiles the SCSS file at path to CSS. It supports a
+// subset of SCSS: variables, imports of other SCSS files and partials,
+// and "//" comments. Other SCSS features, such as nested rules, mixins,
+// control directives, and interpolation, are reported as errors rather
+// than written as invalid CSS, as is the indented ".sass" syntax.
+func compileSCSS(path string) ([]byte, error) {
+	if filepath.Ext(path) == ".sass" {
+		return nil, fmt.Errorf("%s: the indented .sass syntax is not supported; use .scss", path)
+	}
+	vars := make(map[string]string)
+	src, err := expandSCSS(path, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for len(src) > 0 {
+		loc := scssVarDecl.FindSubmatchIndex(src)
+		end := len(src)
+		if loc != nil {
+			end = loc[0]
+		}
+		chunk, err := substituteVars(src[:end], vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		out.Write(chunk)
+		if loc == nil {
+			break
+		}
+		name := string(src[loc[2]:loc[3]])
+		if _, ok := vars[name]; !ok || loc[6] < 0 {
+			val, err := substituteVars(src[loc[4]:loc[5]], vars)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", path, err)
+			}
+			vars[name] = string(val)
+		}
+		src = src[loc[1]:]
+	}
+	if err := checkSCSS(out.Bytes()); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return out.Bytes(), nil
+}
+
+// expandSCSS returns the contents of the SCSS file at path with
+// comments removed and imports replaced by the imported files.
+func expandSCSS(path string, seen map[string]bool) ([]byte, error) {
+	if seen[path] {
+		return nil, fmt.Errorf("%s: import cycle", path)
+	}
+	seen[path] = true
+	defer delete(seen, path)
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	src = stripSCSSComments(src)
+
+	var out bytes.Buffer
+	for {
+		loc := scssImport.FindSubmatchIndex(src)
+		if loc == nil {
+			out.Write(src)
+			return out.Bytes(), nil
+		}
+		out.Write(src[:loc[0]])
+		name := ""
+		if loc[2] >= 0 {
+			name = string(src[loc[2]:loc[3]])
+		} else {
+			name = string(src[loc[4]:loc[5]])
+		}
+		if strings.HasSuffix(name, ".css") || strings.Contains(name, "://") {
+			// Plain CSS import, left for the browser.
+			out.Write(src[loc[0]:loc[1]])
+		} else {
+			ipath, err := resolveSCSSImport(filepath.Dir(path), name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", path, err)
+			}
+			imported, err := expandSCSS(ipath, seen)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(imported)
+		}
+		src = src[loc[1]:]
+	}
+}
+
+// resolveSCSSImport returns the path of the file imported by name
+// from a file in dir, trying the partial "_name.scss" before "name.scss".
+func resolveSCSSImport(dir, name string) (string, error) {
+	name = filepath.FromSlash(strings.TrimSuffix(name, ".scss"))
+	d, base := filepath.Split(name)
+	for _, p := range []string{
+		filepath.Join(dir, d, "_"+base+".scss"),
+		filepath.Join(dir, d, base+".scss"),
+	} {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find import %q", name)
+}
+
+// substituteVars replaces variable references in b with their values
+// in vars. References in strings and "/* */" comments are kept.
+func substituteVars(b []byte, vars map[string]string) ([]byte, error) {
+	var err error
+	ret := mapSCSSCode(b, func(code []byte) []byte {
+		return scssVarUse.ReplaceAllFunc(code, func(m []byte) []byte {
+			v, ok := vars[string(m[1:])]
+			if !ok {
+				if err == nil {
+					err = fmt.Errorf("undefined variable %s", m)
+				}
+				return m
+			}
+			return []byte(v)
+		})
+	})
+	return ret, err
+}
+
+// checkSCSS returns an error for the SCSS features in css, the output
+// of compileSCSS, that are not supported: the at-rules in
+// scssUnsupported, interpolation, and rules nested in other rules.
+// Rules nested in at-rules, as in "@media", are valid CSS.
+func checkSCSS(css []byte) error {
+	var (
+		err      error
+		prelude  bytes.Buffer // Text since the end of the last statement or block.
+		atBlocks []bool       // For each open block, whether it is an at-rule.
+	)
+	mapSCSSCode(css, func(code []byte) []byte {
+		if err != nil {
+			return code
+		}
+		for _, m := range scssAtRule.FindAll(code, -1) {
+			if scssUnsupported[string(m)] {
+				err = fmt.Errorf("%s is not supported", m)
+				return code
+			}
+		}
+		if bytes.Contains(code, []byte("#{")) {
+			err = fmt.Errorf("interpolation with #{} is not supported")
+			return code
+		}
+		for _, c := range code {
+			switch c {
+			case '{':
+				sel := strings.TrimSpace(prelude.String())
+				if n := len(atBlocks); n > 0 && !atBlocks[n-1] {
+					err = fmt.Errorf("nested rule %q is not supported", sel)
+					return code
+				}
+				atBlocks = append(atBlocks, strings.HasPrefix(sel, "@"))
+				prelude.Reset()
+			case '}':
+				if n := len(atBlocks); n > 0 {
+					atBlocks = atBlocks[:n-1]
+				}
+				prelude.Reset()
+			case ';':
+				prelude.Reset()
+			default:
+				prelude.WriteByte(c)
+			}
+		}
+		return code
+	})
+	return err
+}
+
+// stripSCSSComments returns src without "//" comments, which run to the
+// end of the line. A line with only a comment is removed. Strings,
+// "/* */" comments, and unquoted url() arguments, which may contain
+// "//", are kept.
+func stripSCSSComments(src []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(src); {
+		switch {
+		case src[i] == '"' || src[i] == '\'':
+			n := scssStringEnd(src, i)
+			out.Write(src[i:n])
+			i = n
+		case bytes.HasPrefix(src[i:], []byte("/*")):
+			n := scssCommentEnd(src, i)
+			out.Write(src[i:n])
+			i = n
+		case bytes.HasPrefix(src[i:], []byte("url(")):
+			n := bytes.IndexByte(src[i:], ')')
+			if n < 0 {
+				n = len(src) - i - 1
+			}
+			out.Write(src[i : i+n+1])
+			i += n + 1
+		case bytes.HasPrefix(src[i:], []byte("//")):
+			n := bytes.IndexByte(src[i:], '\n')
+			if n < 0 {
+				n = len(src) - i
+			}
+			i += n
+			line := bytes.TrimRight(out.Bytes(), " \t")
+			out.Truncate(len(line))
+			if (len(line) == 0 || line[len(line)-1] == '\n') && i < len(src) {
+				i++ // The newline of a line with only a comment.
+			}
+		default:
+			out.WriteByte(src[i])
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// mapSCSSCode returns src with the parts outside strings and "/* */"
+// comments replaced by the result of fn. Strings and comments are kept.
+func mapSCSSCode(src []byte, fn func(code []byte) []byte) []byte {
+	var out bytes.Buffer
+	start := 0
+	for i := 0; i < len(src); {
+		var n int
+		switch {
+		case src[i] == '"' || src[i] == '\'':
+			n = scssStringEnd(src, i)
+		case bytes.HasPrefix(src[i:], []byte("/*")):
+			n = scssCommentEnd(src, i)
+		default:
+			i++
+			continue
+		}
+		out.Write(fn(src[start:i]))
+		out.Write(src[i:n])
+		i, start = n, n
+	}
+	out.Write(fn(src[start:]))
+	return out.Bytes()
+}
+
+// scssStringEnd returns the index after the end of the string starting
+// with the quote at src[i], or len(src) if the string is not closed.
+func scssStringEnd(src []byte, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// scssCommentEnd returns the index after the end of the "/* */"
+// comment starting at src[i], or len(src) if it is not closed.
+func scssCommentEnd(src []byte, i int) int {
+	n := bytes.Index(src[i+2:], []byte("*/"))
+	if n < 0 {
+		return len(src)
+	}
+	return i + 2 + n + 2
+}
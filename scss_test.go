@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileSCSS(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		files    map[string]string
+		expected string
+		err      string
+	}{
+		{
+			map[string]string{
+				"style.scss": "$red: #f00;\na { color: $red; }\n",
+			},
+			"a { color: #f00; }\n",
+			"",
+		},
+		{
+			map[string]string{
+				"style.scss":      "// Site styles.\n@import \"colors\";\n@import 'lib/sizes.scss';\nh1 { color: $fg; font-size: $big; }\n",
+				"_colors.scss":    "$base: #333;\n$fg: $base;\n",
+				"lib/_sizes.scss": "$big: 2em !default;\n$big: 3em !default;\n",
+			},
+			"h1 { color: #333; font-size: 2em; }\n",
+			"",
+		},
+		{
+			map[string]string{
+				"style.scss": "@import \"https://fonts.example.com/a.css\";\na { color: red; }\n",
+			},
+			"@import \"https://fonts.example.com/a.css\";\na { color: red; }\n",
+			"",
+		},
+		{
+			map[string]string{
+				"style.scss": "$fg: red;\na { color: $fg; } // Trailing comment.\na::before { content: \"$5 // not a comment\"; }\n" +
+					"b { background: url(http://example.com/a.png); }\n/* $fg stays */\n@media (min-width: 40em) {\n  a { color: $fg; }\n}\n",
+			},
+			"a { color: red; }\na::before { content: \"$5 // not a comment\"; }\n" +
+				"b { background: url(http://example.com/a.png); }\n/* $fg stays */\n@media (min-width: 40em) {\n  a { color: red; }\n}\n",
+			"",
+		},
+		{
+			map[string]string{
+				"style.scss": "nav {\n  a { color: red; }\n}\n",
+			},
+			"",
+			`nested rule "a" is not supported`,
+		},
+		{
+			map[string]string{
+				"style.scss": "@mixin big { font-size: 2em; }\nh1 { @include big; }\n",
+			},
+			"",
+			"@mixin is not supported",
+		},
+		{
+			map[string]string{
+				"style.scss": "$side: left;\na { margin-#{$side}: 0; }\n",
+			},
+			"",
+			"interpolation",
+		},
+		{
+			map[string]string{
+				"style.scss": "a { color: $missing; }\n",
+			},
+			"",
+			"undefined variable $missing",
+		},
+		{
+			map[string]string{
+				"style.scss": "@import \"missing\";\n",
+			},
+			"",
+			`cannot find import "missing"`,
+		},
+		{
+			map[string]string{
+				"style.scss": "@import \"a\";\n",
+				"_a.scss":    "@import \"style\";\n",
+			},
+			"",
+			"import cycle",
+		},
+	}
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"style.sass": "a\n  color: red\n"})
+	if _, err := compileSCSS(filepath.Join(dir, "style.sass")); err == nil || !strings.Contains(err.Error(), ".sass syntax is not supported") {
+		t.Fatalf("compileSCSS .sass: got error %v, expected unsupported", err)
+	}
+
+	for i, tc := range testcases {
+		dir := t.TempDir()
+		writeTree(t, dir, tc.files)
+		got, err := compileSCSS(filepath.Join(dir, "style.scss"))
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("%d: compileSCSS: got error %v, expected %q", i, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: compileSCSS: %s", i, err)
+		}
+		if string(got) != tc.expected {
+			t.Fatalf("%d: compileSCSS: got %q, expected %q", i, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildSCSS(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/css/style.scss":    "@import \"vars\";\nbody {\n\tcolor: $fg;\n}\n",
+		"src/css/_vars.scss":    "$fg: red;\n",
+		"src/css/broken/x.scss": "",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := readFile(t, "build/css/style.css"), "body{color:red}"; got != expected {
+		t.Fatalf("build/css/style.css: got %q, expected %q", got, expected)
+	}
+	for _, name := range []string{"build/css/style.scss", "build/css/_vars.scss", "build/css/_vars.css"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s: got %v, expected not to exist", name, err)
+		}
+	}
+
+	// Errors name the file.
+	writeTree(t, dir, map[string]string{"src/css/broken/x.scss": "a { color: $nope; }"})
+	err := (&Build{}).Run()
+	if err == nil || !strings.Contains(err.Error(), filepath.Join("src", "css", "broken", "x.scss")) {
+		t.Fatalf("Run: got error %v, expected it to name the file", err)
+	}
+}
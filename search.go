@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"html/template"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SearchIndexFile is the name of the search index file in the output
+// directory.
+const SearchIndexFile = "index.json"
+
+// SearchIndex writes a JSON index of pages for client-side search.
+//
+// Example index:
+//
+//	[
+//	  {
+//	    "title": "Hello, world",
+//	    "path": "/blog/hello",
+//	    "time": "2006-01-02T15:04:05-07:00",
+//	    "description": "A first post",
+//	    "tags": ["hello"]
+//	  }
+//	]
+//
+// The description is the page's front matter description, or the plain
+// text of its summary if there is no description.
+type SearchIndex struct{}
+
+type searchEntry struct {
+	Title       string    `json:"title"`
+	Path        string    `json:"path"`
+	Time        time.Time `json:"time"`
+	Description string    `json:"description"`
+	Tags        []string  `json:"tags"`
+}
+
+// Write writes the index for pages to w. Draft pages are excluded.
+func (SearchIndex) Write(w io.Writer, pages []*Page) error {
+	entries := make([]searchEntry, 0, len(pages))
+	for _, p := range pages {
+		if p.Draft {
+			continue
+		}
+		desc := p.Description
+		if desc == "" {
+			desc = plainText(p.Summary)
+		}
+		tags := p.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		entries = append(entries, searchEntry{
+			Title:       p.Title,
+			Path:        p.Path,
+			Time:        p.Time,
+			Description: desc,
+			Tags:        tags,
+		})
+	}
+	return json.NewEncoder(w).Encode(entries)
+}
+
+var htmlTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// plainText returns the text in h with HTML tags removed and
+// entities unescaped.
+func plainText(h template.HTML) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagRe.ReplaceAllString(string(h), "")))
+}
+
+// writeSearchIndex writes the search index for pages, sorted by ByTime,
+// to the named file.
+func writeSearchIndex(name string, pages map[string]*Page) error {
+	sorted := make([]*Page, 0, len(pages))
+	for _, p := range pages {
+		sorted = append(sorted, p)
+	}
+	sort.Sort(ByTime(sorted))
+
+	buf := bytes.Buffer{}
+	if err := (SearchIndex{}).Write(&buf, sorted); err != nil {
+		return err
+	}
+	_, err := writeIfChanged(name, buf.Bytes())
+	return err
+}
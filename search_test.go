@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSearchIndex(t *testing.T) {
+	t.Parallel()
+
+	tm := time.Date(2016, 8, 28, 10, 0, 0, 0, time.UTC)
+	pages := []*Page{
+		{
+			Title:       "Hello",
+			Path:        "/blog/hello",
+			Time:        tm,
+			Description: "A first post",
+			Tags:        []string{"go", "web"},
+			Content:     "<p>full content</p><p>more</p>",
+		},
+		{
+			Title:   "Summary",
+			Path:    "/blog/summary",
+			Time:    tm,
+			Summary: "<p>Fish &amp; <em>chips</em></p>",
+		},
+		{
+			Title: "Draft",
+			Path:  "/blog/draft",
+			Draft: true,
+		},
+	}
+
+	buf := bytes.Buffer{}
+	if err := (SearchIndex{}).Write(&buf, pages); err != nil {
+		t.Fatal(err)
+	}
+
+	var res []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{
+		{
+			"title":       "Hello",
+			"path":        "/blog/hello",
+			"time":        "2016-08-28T10:00:00Z",
+			"description": "A first post",
+			"tags":        []interface{}{"go", "web"},
+		},
+		{
+			"title":       "Summary",
+			"path":        "/blog/summary",
+			"time":        "2016-08-28T10:00:00Z",
+			"description": "Fish & chips",
+			"tags":        []interface{}{},
+		},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("SearchIndex: got %v, expected %v", res, expected)
+	}
+}
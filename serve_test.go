@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServeListener(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html": "<p>home</p>",
+	})
+	t.Chdir(dir)
+
+	s := &Serve{Build: &Build{NoMinify: true}, HTTP: "localhost:0"}
+	ln, err := net.Listen("tcp", s.HTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	if addr.Port == 0 {
+		t.Fatalf("listener: got port 0, expected a chosen port")
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.serve(context.Background(), ln) }()
+	defer func() {
+		ln.Close()
+		<-done
+	}()
+
+	// A connection made before the build finishes waits in the listen
+	// backlog until the server accepts it.
+	resp, err := http.Get("http://" + addr.String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(b) != "<p>home</p>" {
+		t.Fatalf("GET /: got %d %q, expected 200 %q", resp.StatusCode, b, "<p>home</p>")
+	}
+}
+
+func TestServeNoBuild(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html": "<p>new</p>",
+	})
+	t.Chdir(dir)
+
+	s := &Serve{Build: &Build{}, HTTP: "localhost:0", NoBuild: true}
+	ln, err := net.Listen("tcp", s.HTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.serve(context.Background(), ln)
+	if err == nil || !strings.Contains(err.Error(), `"build" directory does not exist`) {
+		t.Fatalf("serve: got %v, expected missing directory error", err)
+	}
+
+	writeTree(t, dir, map[string]string{
+		"build/index.html": "<p>old</p>",
+	})
+	ln, err = net.Listen("tcp", s.HTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.serve(context.Background(), ln) }()
+	defer func() {
+		ln.Close()
+		<-done
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(b) != "<p>old</p>" {
+		t.Fatalf("GET /: got %d %q, expected 200 %q", resp.StatusCode, b, "<p>old</p>")
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html": "<p>home</p>",
+	})
+	t.Chdir(dir)
+
+	s := &Serve{Build: &Build{}, HTTP: "localhost:0", Watch: true, LiveReload: true}
+	ln, err := net.Listen("tcp", s.HTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.serve(ctx, ln) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// A live reload connection does not keep the server running.
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET " + liveReloadPath + " HTTP/1.1\r\nHost: localhost\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("serve: got error %v after shutdown, expected nil", err)
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/"); err == nil {
+		t.Fatalf("GET / after shutdown: expected error")
+	}
+}
+
+func TestServeRebuildConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"batsman.toml":    "title = \"Old\"\n",
+		"src/layout.tmpl": `{{ .Site.Title }}: {{ .Current.Title }}`,
+		"src/a.md":        "+++\ntitle = \"a\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	reload := func(b *Build) error {
+		config, err := LoadConfig(DefaultConfigFile, "")
+		if err != nil {
+			return err
+		}
+		config.apply(b)
+		return nil
+	}
+	s := &Serve{Build: &Build{}, ConfigFile: DefaultConfigFile, Reload: reload}
+	if err := reload(s.Build); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Build.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An unchanged page is rebuilt with the new config.
+	writeTree(t, dir, map[string]string{"batsman.toml": "title = \"New\"\n"})
+	if err := s.rebuild(filepath.Join(dir, DefaultConfigFile)); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := readFile(t, "build/a/index.html"), "New: a"; got != expected {
+		t.Fatalf("build/a/index.html: got %q, expected %q", got, expected)
+	}
+}
+
+func TestServeQuiet(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html": "<p>home</p>",
+	})
+	t.Chdir(dir)
+
+	var buf bytes.Buffer
+	stderr.SetOutput(&buf)
+	defer stderr.SetOutput(os.Stderr)
+	defer setQuiet(false)
+
+	for _, quiet := range []bool{false, true} {
+		buf.Reset()
+		setQuiet(quiet)
+
+		s := &Serve{Build: &Build{}, HTTP: "localhost:0"}
+		ln, err := net.Listen("tcp", s.HTTP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error, 1)
+		go func() { done <- s.serve(context.Background(), ln) }()
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		ln.Close()
+		<-done
+
+		if got := strings.Contains(buf.String(), "serving"); got == quiet {
+			t.Fatalf("quiet=%t: got output %q", quiet, buf.String())
+		}
+		stderr.Println("error: x")
+		if !strings.Contains(buf.String(), "error: x") {
+			t.Fatalf("quiet=%t: got output %q, expected errors to be printed", quiet, buf.String())
+		}
+	}
+}
+
+func TestServeOpen(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html": "<p>home</p>",
+	})
+	t.Chdir(dir)
+
+	var buf bytes.Buffer
+	stderr.SetOutput(&buf)
+	defer stderr.SetOutput(os.Stderr)
+
+	var opened string
+	defer func(f func(string, ...string) error) { startCommand = f }(startCommand)
+	startCommand = func(name string, args ...string) error {
+		opened = args[len(args)-1]
+		return errors.New("no browser")
+	}
+
+	s := &Serve{Build: &Build{}, HTTP: "localhost:0", Open: true}
+	ln, err := net.Listen("tcp", s.HTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.serve(context.Background(), ln) }()
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	ln.Close()
+	<-done
+
+	if expected := "http://" + ln.Addr().String() + "/"; opened != expected {
+		t.Fatalf("opened %q, expected %q", opened, expected)
+	}
+	if !strings.Contains(buf.String(), "warning: open browser: no browser") {
+		t.Fatalf("got output %q, expected a warning", buf.String())
+	}
+}
+
+func TestUnderPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"index.html":    "<p>home</p>",
+		"css/style.css": "p{}",
+	})
+	h := underPath("/blog", http.FileServer(http.Dir(dir)))
+
+	testcases := []struct {
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{"/blog/", http.StatusOK, "<p>home</p>", ""},
+		{"/blog/css/style.css", http.StatusOK, "p{}", ""},
+		{"/blog", http.StatusMovedPermanently, "", "/blog/"},
+		{"/", http.StatusFound, "", "/blog/"},
+		{"/css/style.css", http.StatusNotFound, "", ""},
+	}
+
+	for _, tc := range testcases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", tc.path, nil))
+		if rec.Code != tc.code {
+			t.Fatalf("GET %s: got status %d, expected %d", tc.path, rec.Code, tc.code)
+		}
+		if tc.body != "" && rec.Body.String() != tc.body {
+			t.Fatalf("GET %s: got body %q, expected %q", tc.path, rec.Body.String(), tc.body)
+		}
+		if got := rec.Header().Get("Location"); got != tc.location {
+			t.Fatalf("GET %s: got Location %q, expected %q", tc.path, got, tc.location)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+)
+
+// SitemapFile is the name of the sitemap file in the output directory.
+const SitemapFile = "sitemap.xml"
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// writeSitemapXML writes a sitemap of pages, with their URLs under
+// baseURL, to w. Drafts and pages with an output extension, which are
+// not HTML, are excluded. The pages are sorted by path.
+func writeSitemapXML(w io.Writer, baseURL string, pages []*Page) error {
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, p := range pages {
+		if p.Draft || p.Output != "" {
+			continue
+		}
+		u := sitemapURL{Loc: joinURL(baseURL, p.Path)}
+		if !p.Time.IsZero() {
+			u.LastMod = p.Time.Format("2006-01-02")
+		}
+		set.URLs = append(set.URLs, u)
+	}
+	sort.Slice(set.URLs, func(i, j int) bool { return set.URLs[i].Loc < set.URLs[j].Loc })
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeSitemap writes the sitemap of the markdown pages to the named
+// file. Sitemaps list absolute URLs, so baseURL must be non-empty.
+func writeSitemap(name, baseURL string, pages map[string]*Page) error {
+	if baseURL == "" {
+		return errors.New("sitemap: a base URL is required, such as with -baseurl")
+	}
+	list := make([]*Page, 0, len(pages))
+	for _, p := range pages {
+		list = append(list, p)
+	}
+	buf := bytes.Buffer{}
+	if err := writeSitemapXML(&buf, baseURL, list); err != nil {
+		return err
+	}
+	_, err := writeIfChanged(name, buf.Bytes())
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildSitemap(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":     "{{ .Current.Title }}",
+		"src/layout.txt.tmpl": "{{ .Current.Title }}",
+		"src/index.html":      "home",
+		"src/blog/a.md":       "+++\ntitle = \"a\"\ntime = \"2016-03-04\"\n+++\n",
+		"src/blog/b.md":       "+++\ntitle = \"b\"\ntime = \"2016-03-05\"\n+++\n",
+		"src/draft.md":        "+++\ndraft = true\n+++\n",
+		"src/notes.md":        "+++\ntitle = \"notes\"\noutput = \"txt\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	if err := (&Build{Sitemap: true, BaseURL: "https://example.com/"}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/blog/a</loc>
+    <lastmod>2016-03-04</lastmod>
+  </url>
+  <url>
+    <loc>https://example.com/blog/b</loc>
+    <lastmod>2016-03-05</lastmod>
+  </url>
+</urlset>
+`
+	if got := readFile(t, "build/sitemap.xml"); got != expected {
+		t.Fatalf("build/sitemap.xml: got %s, expected %s", got, expected)
+	}
+
+	err := (&Build{Sitemap: true}).Run()
+	if err == nil || !strings.Contains(err.Error(), "base URL is required") {
+		t.Fatalf("Run without base URL: got error %v, expected base URL required", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// sriDigest returns the subresource integrity value of data, such as
+// "sha384-...", for use in integrity attributes.
+func sriDigest(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sri returns the subresource integrity value of the built asset at HTTP
+// path p. If fingerprinting is enabled, p may be the asset's name before
+// or after fingerprinting. Only CSS, JS, and SVG assets are built before
+// templates are executed, so other files result in an error. Values are
+// cached by path.
+func (a *assetNames) sri(p string) (string, error) {
+	p = path.Clean("/" + p)
+	if _, ok := minifyFuncs[path.Ext(p)]; !ok {
+		return "", fmt.Errorf("sri: %q is not a CSS, JS, or SVG asset", p)
+	}
+
+	a.mx.Lock()
+	if d, ok := a.digests[p]; ok {
+		a.mx.Unlock()
+		return d, nil
+	}
+	name := p
+	if fp, ok := a.m[p]; ok && a.enabled {
+		name = fp
+	}
+	a.mx.Unlock()
+
+	data, err := ioutil.ReadFile(filepath.Join(a.dir, filepath.FromSlash(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("sri: no built asset at %q", p)
+		}
+		return "", fmt.Errorf("sri: %s", err)
+	}
+	d := sriDigest(data)
+
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if a.digests == nil {
+		a.digests = make(map[string]string)
+	}
+	a.digests[p] = d
+	return d, nil
+}
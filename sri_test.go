@@ -0,0 +1,50 @@
+package main
+
+import (
+	"html"
+	"strings"
+	"testing"
+)
+
+func TestSRIDigest(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		data, expected string
+	}{
+		{"", "sha384-OLBgp1GsljhM2TJ+sbHjaiH9txEUvgdDTAzHv2P24donTt6/529l+9Ua0vFImLlb"},
+		{"alert('Hello, world.');", "sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO"},
+	}
+
+	for _, tc := range testcases {
+		if got := sriDigest([]byte(tc.data)); got != tc.expected {
+			t.Fatalf("sriDigest(%q): got %q, expected %q", tc.data, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildSRI(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/js/app.js":  "alert('Hello, world.');",
+		"src/index.html": `<script integrity="{{ sri "/js/app.js" }}"></script><script integrity="{{ sri (fingerprint "js/app.js") }}"></script>`,
+	})
+	t.Chdir(dir)
+
+	const expected = `<script integrity="sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO"></script>`
+	for _, fingerprint := range []bool{false, true} {
+		if err := (&Build{NoMinify: true, Fingerprint: fingerprint}).Run(); err != nil {
+			t.Fatal(err)
+		}
+		// Browsers unescape attribute values, such as "&#43;" for "+".
+		if got := html.UnescapeString(readFile(t, "build/index.html")); got != expected+expected {
+			t.Fatalf("build/index.html with fingerprint %t: got %q, expected %q", fingerprint, got, expected+expected)
+		}
+	}
+
+	writeTree(t, dir, map[string]string{"src/index.html": `{{ sri "/js/missing.js" }}`})
+	err := (&Build{}).Run()
+	if err == nil || !strings.Contains(err.Error(), `no built asset at "/js/missing.js"`) {
+		t.Fatalf("Run: got error %v, expected no built asset", err)
+	}
+}
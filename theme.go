@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultTheme is the starter site written by init if no theme is
+// specified.
+const DefaultTheme = "minimal"
+
+// themes is a map from theme name to the files of the starter site,
+// keyed by slash-separated path relative to the site root.
+var themes = map[string]map[string][]byte{
+	"minimal": minimalFiles,
+	"blog":    blogFiles,
+	"docs":    rawFiles,
+}
+
+// themeFiles returns the files for the named theme.
+func themeFiles(name string) (map[string][]byte, error) {
+	files, ok := themes[name]
+	if !ok {
+		names := make([]string, 0, len(themes))
+		for k := range themes {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown theme %q, expected one of: %s", name, strings.Join(names, ", "))
+	}
+	return files, nil
+}
+
+var minimalFiles = map[string][]byte{
+	"src/layout.tmpl": []byte(`<!doctype html>
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{ .Current.Title }}</title>
+<p><a href="/">Home</a></p>
+<article>
+{{ .Current.Content }}
+</article>
+`),
+	"src/index.html": []byte(`<!doctype html>
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>Home</title>
+<h1>Home</h1>
+<ul>
+{{ range .Dir }}<li><a href="{{ .Path }}">{{ .Title }}</a></li>
+{{ end }}</ul>
+`),
+	"src/hello.md": []byte(`+++
+title = "Hello, world"
++++
+
+This page is generated from ` + "`src/hello.md`" + ` using ` + "`src/layout.tmpl`" + `.
+`),
+}
+
+var blogFiles = map[string][]byte{
+	"src/_partials/head.tmpl": []byte(`{{ define "head" }}<!doctype html>
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<link rel="stylesheet" href="/css/style.css" />
+<header><a href="/">Home</a> <a href="/blog/">Blog</a></header>
+{{ end }}
+`),
+	"src/layout.tmpl": []byte(`{{ template "head" . }}
+<title>{{ .Current.Title }}</title>
+<main>
+{{ block "content" . }}{{ .Current.Content }}{{ end }}
+</main>
+`),
+	"src/blog/layout.tmpl": []byte(`{{ define "content" }}<article>
+<h1>{{ .Current.Title }}</h1>
+<p><time>{{ formatTime .Current.Time "long" }}</time></p>
+{{ .Current.Content }}
+<nav>
+{{ with .Current.Prev }}<a href="{{ .Path }}">&larr; {{ .Title }}</a>{{ end }}
+{{ with .Current.Next }}<a href="{{ .Path }}">{{ .Title }} &rarr;</a>{{ end }}
+</nav>
+</article>{{ end }}
+`),
+	"src/index.html": []byte(`{{ template "head" . }}
+<title>Home</title>
+<main>
+<h1>Home</h1>
+<h2>Recent posts</h2>
+<ul>
+{{ range index .All "blog" }}<li><a href="{{ .Path }}">{{ .Title }}</a> <time>{{ formatTime .Time "date" }}</time></li>
+{{ end }}</ul>
+</main>
+`),
+	"src/blog/index.html": []byte(`{{ template "head" . }}
+<title>Blog</title>
+<main>
+<h1>Blog</h1>
+{{ range .Paginator.Pages }}<article>
+<h2><a href="{{ .Path }}">{{ .Title }}</a></h2>
+<p><time>{{ formatTime .Time "long" }}</time></p>
+{{ .Summary }}
+</article>
+{{ end }}
+<nav>
+{{ with .Paginator.PrevURL }}<a href="{{ . }}">Newer</a>{{ end }}
+{{ with .Paginator.NextURL }}<a href="{{ . }}">Older</a>{{ end }}
+</nav>
+</main>
+`),
+	"src/blog/hello-world.md": []byte(`+++
+title = "Hello, world"
+time = "2016-01-02"
+tags = ["hello"]
++++
+
+This is the first post. Posts live in ` + "`src/blog/`" + ` and are listed in
+` + "`src/blog/index.html`" + `.
+`),
+	"src/blog/second-post.md": []byte(`+++
+title = "Second post"
+time = "2016-01-09"
++++
+
+The first paragraph is the summary shown in the post list.
+
+The rest of the post is only shown on its own page.
+`),
+	"src/css/style.css": []byte(`body {
+	max-width: 40em;
+	margin: 0 auto;
+	padding: 1em;
+	font-family: sans-serif;
+	line-height: 1.5;
+}
+
+header a {
+	margin-right: 1em;
+}
+
+time {
+	color: #666;
+}
+`),
+}
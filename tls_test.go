@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestSelfSignedCert(t *testing.T) {
+	t.Parallel()
+
+	cert, err := selfSignedCert("localhost", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.Certificate) != 1 || cert.PrivateKey == nil {
+		t.Fatalf("selfSignedCert: got %d certificates and key %v, expected 1 and a key", len(cert.Certificate), cert.PrivateKey)
+	}
+	c, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(c)
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		if _, err := c.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, CurrentTime: time.Now()}); err != nil {
+			t.Fatalf("selfSignedCert: %s: %s", host, err)
+		}
+	}
+	if err := c.VerifyHostname("example.com"); err == nil {
+		t.Fatal("selfSignedCert: expected example.com to be invalid")
+	}
+}
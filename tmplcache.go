@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"sync"
+)
+
+// TemplateCache caches parsed layout and .html templates across runs of
+// a Build, such as the rebuilds of "serve -watch". A template is parsed
+// again if any of its files, or a partial, is modified. The zero value
+// is an empty cache. It is safe for concurrent use.
+type TemplateCache struct {
+	mx     sync.Mutex
+	m      map[string]*cachedTemplate // Keyed by the template's files.
+	parses int                        // Number of templates parsed.
+}
+
+type cachedTemplate struct {
+	stamp string             // Modification times and sizes of the files.
+	tmpl  *template.Template // Never executed, so that it can be cloned.
+}
+
+// parse is like parseTemplate, but reuses the cached template for files
+// if neither files nor deps, such as the partial files, have changed
+// since it was parsed. The returned template is a copy that uses funcs,
+// since the functions of a previous run may refer to stale data.
+//
+// A nil cache parses the files every time.
+func (c *TemplateCache) parse(partials *template.Template, deps []string, funcs template.FuncMap, files ...string) (*template.Template, error) {
+	if c == nil {
+		return parseTemplate(partials, files...)
+	}
+
+	stamp, err := modStamp(append(append([]string(nil), deps...), files...))
+	if err != nil {
+		return nil, err
+	}
+	key := strings.Join(files, "\x00")
+
+	c.mx.Lock()
+	e := c.m[key]
+	c.mx.Unlock()
+
+	if e == nil || e.stamp != stamp {
+		t, err := parseTemplate(partials, files...)
+		if err != nil {
+			return nil, err
+		}
+		e = &cachedTemplate{stamp: stamp, tmpl: t}
+		c.mx.Lock()
+		if c.m == nil {
+			c.m = make(map[string]*cachedTemplate)
+		}
+		c.m[key] = e
+		c.parses++
+		c.mx.Unlock()
+	}
+
+	t, err := e.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return t.Funcs(funcs), nil
+}
+
+// modStamp returns a string that changes when any of the named files is
+// modified.
+func modStamp(names []string) (string, error) {
+	var b strings.Builder
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", name, info.ModTime().UnixNano(), info.Size())
+	}
+	return b.String(), nil
+}
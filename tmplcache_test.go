@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTemplateCache(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/_partials/head.tmpl": `{{ define "head" }}head{{ end }}`,
+		"src/layout.tmpl":         `{{ template "head" }}:{{ .Current.Title }}`,
+		"src/index.html":          `{{ range .Dir }}{{ .Title }}{{ end }}`,
+		"src/post.md":             "+++\ntitle = \"one\"\n+++\n",
+	})
+	t.Chdir(dir)
+
+	cache := &TemplateCache{}
+	b := &Build{Templates: cache}
+	run := func(expectedParses int, expectedPost, expectedIndex string) {
+		t.Helper()
+		if err := b.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if cache.parses != expectedParses {
+			t.Fatalf("parses: got %d, expected %d", cache.parses, expectedParses)
+		}
+		if got := readFile(t, "build/post/index.html"); got != expectedPost {
+			t.Fatalf("build/post/index.html: got %q, expected %q", got, expectedPost)
+		}
+		if got := readFile(t, "build/index.html"); got != expectedIndex {
+			t.Fatalf("build/index.html: got %q, expected %q", got, expectedIndex)
+		}
+	}
+	touch := func(name, data string) {
+		t.Helper()
+		writeTree(t, dir, map[string]string{name: data})
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(name, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run(2, "head:one", "one")
+
+	// Unchanged templates are reused with the pages of the new run.
+	touch("src/post.md", "+++\ntitle = \"two\"\n+++\n")
+	run(2, "head:two", "two")
+
+	touch("src/layout.tmpl", `{{ template "head" }}={{ .Current.Title }}`)
+	run(3, "head=two", "two")
+
+	// A changed partial affects every template.
+	touch("src/_partials/head.tmpl", `{{ define "head" }}HEAD{{ end }}`)
+	run(5, "HEAD=two", "two")
+}
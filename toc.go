@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// tocPlaceholder is output by the TOC plugin and replaced with the
+// table of contents after markdown is rendered.
+const tocPlaceholder = "<!--batsman:toc-->"
+
+// insertTOC replaces the TOC placeholder in content with a nested list
+// of links to the h2-h4 headings in content. The headings are expected
+// to have ids, as set by addHeadingIDs.
+func insertTOC(content template.HTML) template.HTML {
+	s := string(content)
+	if !strings.Contains(s, tocPlaceholder) {
+		return content
+	}
+
+	type heading struct {
+		level int
+		id    string
+		text  string
+	}
+	var headings []heading
+	base := 4 // Level of the outermost list.
+
+	for _, sub := range headingRe.FindAllStringSubmatch(s, -1) {
+		level, _ := strconv.Atoi(sub[1])
+		id := idAttrRe.FindStringSubmatch(sub[2])
+		if level < 2 || level > 4 || id == nil {
+			continue
+		}
+		if level < base {
+			base = level
+		}
+		headings = append(headings, heading{level, id[1], plainText(template.HTML(sub[3]))})
+	}
+
+	buf := bytes.Buffer{}
+	depth := 0 // Number of open lists.
+	for _, h := range headings {
+		want := h.level - base + 1
+		if want > depth {
+			for ; depth < want; depth++ {
+				buf.WriteString("<ul><li>")
+			}
+		} else {
+			for ; depth > want; depth-- {
+				buf.WriteString("</li></ul>")
+			}
+			buf.WriteString("</li><li>")
+		}
+		fmt.Fprintf(&buf, "<a href=\"#%s\">%s</a>", h.id, template.HTMLEscapeString(h.text))
+	}
+	for ; depth > 0; depth-- {
+		buf.WriteString("</li></ul>")
+	}
+
+	toc := buf.String()
+	s = strings.Replace(s, "<p>"+tocPlaceholder+"</p>", toc, -1)
+	s = strings.Replace(s, tocPlaceholder, toc, -1)
+	return template.HTML(s)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestInsertTOC(t *testing.T) {
+	t.Parallel()
+
+	in := template.HTML(`<h1>Title</h1>
+<p>` + tocPlaceholder + `</p>
+<h2 id="kept">Intro</h2>
+<h3>Details &amp; more</h3>
+<h3>Details &amp; more</h3>
+<h5>Too deep</h5>
+<h2>End</h2>`)
+	expected := template.HTML(`<h1 id="title">Title</h1>
+<ul><li><a href="#kept">Intro</a><ul><li><a href="#details-more">Details &amp; more</a></li><li><a href="#details-more-1">Details &amp; more</a></li></ul></li><li><a href="#end">End</a></li></ul>
+<h2 id="kept">Intro</h2>
+<h3 id="details-more">Details &amp; more</h3>
+<h3 id="details-more-1">Details &amp; more</h3>
+<h5 id="too-deep">Too deep</h5>
+<h2 id="end">End</h2>`)
+
+	if res := insertTOC(addHeadingIDs(in)); res != expected {
+		t.Fatalf("insertTOC: got %s, expected %s", res, expected)
+	}
+
+	noTOC := template.HTML(`<h2>Intro</h2>`)
+	if res := insertTOC(noTOC); res != noTOC {
+		t.Fatalf("insertTOC: got %s, expected unchanged %s", res, noTOC)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+)
+
+// joinURL joins the base URL and the root-relative path p, such that
+// exactly one slash separates them. If base is empty, p is returned
+// unchanged. base is not required to have a scheme; it is used as is.
+func joinURL(base, p string) string {
+	if base == "" {
+		return p
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(p, "/")
+}
+
+// relURL returns the root-relative path p prefixed with basePath, such
+// as "/blog/css/style.css" for basePath "/blog" and p "css/style.css".
+// basePath is "" or has a leading slash and no trailing slash.
+func relURL(basePath, p string) string {
+	return basePath + "/" + strings.TrimLeft(p, "/")
+}
+
+// canonical returns a canonical link element with the absolute URL of
+// the page. If baseURL is empty, the URL cannot be absolute, so the
+// empty string is returned instead of a relative link.
+func canonical(p *Page, baseURL string) template.HTML {
+	if baseURL == "" || p == nil {
+		return ""
+	}
+	return template.HTML(`<link rel="canonical" href="` + template.HTMLEscapeString(joinURL(baseURL, p.Path)) + `">`)
+}
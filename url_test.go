@@ -0,0 +1,108 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestJoinURL(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		base, p  string
+		expected string
+	}{
+		{"https://x.com/", "/a/b", "https://x.com/a/b"},
+		{"https://x.com", "/a/b", "https://x.com/a/b"},
+		{"https://x.com//", "a/b/", "https://x.com/a/b/"},
+		{"https://x.com/blog/", "/a/", "https://x.com/blog/a/"},
+		{"https://x.com", "", "https://x.com/"},
+		{"x.com", "/a", "x.com/a"},
+		{"//x.com", "/a", "//x.com/a"},
+		{"", "/a/b/", "/a/b/"},
+		{"", "a", "a"},
+	}
+
+	for _, tc := range testcases {
+		if got := joinURL(tc.base, tc.p); got != tc.expected {
+			t.Fatalf("joinURL(%q, %q): got %s, expected %s", tc.base, tc.p, got, tc.expected)
+		}
+	}
+}
+
+func TestRelURL(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		basePath, p string
+		expected    string
+	}{
+		{"/blog", "/css/style.css", "/blog/css/style.css"},
+		{"/blog", "css/style.css", "/blog/css/style.css"},
+		{"/blog", "/", "/blog/"},
+		{"/blog", "", "/blog/"},
+		{"/a/b", "/c/", "/a/b/c/"},
+		{"", "/css/style.css", "/css/style.css"},
+		{"", "css/style.css", "/css/style.css"},
+	}
+
+	for _, tc := range testcases {
+		if got := relURL(tc.basePath, tc.p); got != tc.expected {
+			t.Fatalf("relURL(%q, %q): got %s, expected %s", tc.basePath, tc.p, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildBasePath(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/index.html":       `{{ relURL "/css/style.css" }} {{ range .Recent }}{{ .Path }} {{ end }}`,
+		"src/layout.tmpl":      "{{ .Current.Path }}",
+		"src/about.md":         "+++\ntitle = \"about\"\ntime = \"2016-01-03\"\n+++\n",
+		"src/posts/a.md":       "+++\ntitle = \"a\"\ntime = \"2016-01-01\"\n+++\n",
+		"src/posts/b.md":       "+++\ntitle = \"b\"\ntime = \"2016-01-02\"\n+++\n",
+		"src/posts/index.html": `{{ .Paginator.PrevURL }} {{ .Paginator.NextURL }}`,
+	})
+	t.Chdir(dir)
+
+	for _, basePath := range []string{"/blog", "blog/", "/blog/"} {
+		if err := (&Build{NoMinify: true, BasePath: basePath, PageSize: 1}).Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		testcases := []struct {
+			name, expected string
+		}{
+			{"build/index.html", "/blog/css/style.css /blog/about /blog/posts/b /blog/posts/a "},
+			{"build/about/index.html", "/blog/about"},
+			{"build/posts/index.html", " /blog/posts/page/2/"},
+			{"build/posts/page/2/index.html", "/blog/posts/ "},
+		}
+		for _, tc := range testcases {
+			if got := readFile(t, tc.name); got != tc.expected {
+				t.Fatalf("base path %q: %s: got %q, expected %q", basePath, tc.name, got, tc.expected)
+			}
+		}
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		page     *Page
+		baseURL  string
+		expected template.HTML
+	}{
+		{&Page{Path: "/blog/hello"}, "https://x.com/", `<link rel="canonical" href="https://x.com/blog/hello">`},
+		{&Page{Path: "/a&b"}, "https://x.com", `<link rel="canonical" href="https://x.com/a&amp;b">`},
+		{&Page{Path: "/blog/hello"}, "", ""},
+		{nil, "https://x.com", ""},
+	}
+
+	for _, tc := range testcases {
+		if got := canonical(tc.page, tc.baseURL); got != tc.expected {
+			t.Fatalf("canonical(%v, %q): got %s, expected %s", tc.page, tc.baseURL, got, tc.expected)
+		}
+	}
+}
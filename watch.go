@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/howeyc/fsnotify"
+)
+
+// rebuildDelay is how long to wait after the last change event before
+// rebuilding, so that a burst of events triggers a single rebuild.
+const rebuildDelay = 200 * time.Millisecond
+
+// watchTree adds root and all directories below it to the watcher.
+// Directories inside the build directory are skipped.
+func watchTree(w *fsnotify.Watcher, root, build string) error {
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if inDir(p, build) {
+			return filepath.SkipDir
+		}
+		if err := w.Watch(p); err != nil {
+			stderr.Println("error: watch:", err)
+		}
+		return nil
+	})
+}
+
+// samePath returns whether a and b are the same path once made absolute.
+func samePath(a, b string) bool {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false
+	}
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false
+	}
+	return absA == absB
+}
+
+// inDir returns whether p is the directory dir or a path inside it.
+func inDir(p, dir string) bool {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, abs)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// changes returns a channel that receives the names of changed files
+// reported by the watcher. Paths inside the build directory are
+// ignored, and directories created after startup are added to the
+// watcher. The returned channel is closed when the watcher is closed.
+func changes(w *fsnotify.Watcher, build string) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for e := range w.Event {
+			if inDir(e.Name, build) {
+				continue
+			}
+			if e.IsCreate() {
+				if info, err := os.Stat(e.Name); err == nil && info.IsDir() {
+					if err := watchTree(w, e.Name, build); err != nil {
+						stderr.Println("error: watch:", err)
+					}
+				}
+			}
+			ch <- e.Name
+		}
+	}()
+	return ch
+}
+
+// debounce returns a channel that receives the most recent value from in
+// once no new values have arrived for duration d. The returned channel
+// is closed after in is closed.
+func debounce(in <-chan string, d time.Duration) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		var (
+			last    string
+			pending bool
+			timer   <-chan time.Time
+		)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					if pending {
+						out <- last
+					}
+					return
+				}
+				last, pending = v, true
+				timer = time.After(d)
+			case <-timer:
+				out <- last
+				pending, timer = false, nil
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T) {
+	t.Parallel()
+
+	const n = 20
+	const d = 50 * time.Millisecond
+
+	in := make(chan string)
+	out := debounce(in, d)
+	go func() {
+		for i := 0; i < n; i++ {
+			in <- "src/foo.md"
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	select {
+	case v := <-out:
+		if v != "src/foo.md" {
+			t.Fatalf("debounce: got %q, expected %q", v, "src/foo.md")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("debounce: expected a value, got none")
+	}
+
+	select {
+	case v := <-out:
+		t.Fatalf("debounce: got extra value %q, expected %d events to be collapsed into one", v, n)
+	case <-time.After(4 * d):
+	}
+
+	close(in)
+	if _, ok := <-out; ok {
+		t.Fatal("debounce: expected output channel to be closed")
+	}
+}
+
+func TestInDir(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in       string
+		expected bool
+	}{
+		{"build", true},
+		{"build/css/style.css", true},
+		{"./build/index.html", true},
+		{"src", false},
+		{"src/build/foo.md", false},
+		{"buildings", false},
+	}
+
+	for _, tc := range testcases {
+		if res := inDir(tc.in, "build"); res != tc.expected {
+			t.Fatalf("inDir(%q, %q): got %t, expected %t", tc.in, "build", res, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "net/http"
+
+// draftBanner is inserted into served draft pages.
+const draftBanner = `<div style="position: fixed; top: 0; right: 0; z-index: 2147483647; padding: 0.25em 0.75em; background: #c00; color: #fff; font: bold 14px sans-serif; letter-spacing: 0.1em; pointer-events: none;">DRAFT</div>`
+
+// markDrafts wraps h so that a "DRAFT" banner is inserted into the HTML
+// responses for the paths for which isDraft returns true, so that
+// drafts stand out when previewing without changes to layouts. Other
+// responses are served by h unchanged.
+func markDrafts(isDraft func(p string) bool, h http.Handler) http.Handler {
+	marked := injectScript(h, draftBanner)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isDraft(r.URL.Path) {
+			// The banner cannot be inserted into compressed files.
+			r = r.Clone(r.Context())
+			r.Header.Del("Accept-Encoding")
+			marked.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
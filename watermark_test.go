@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMarkDrafts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"draft/index.html":    "<body><p>draft</p></body>",
+		"draft/index.html.gz": "not gzip",
+		"post/index.html":     "<body><p>post</p></body>",
+	})
+	isDraft := func(p string) bool { return p == "/draft/" || p == "/draft/index.html" }
+	h := markDrafts(isDraft, precompressed(dir, http.FileServer(http.Dir(dir))))
+
+	testcases := []struct {
+		path     string
+		expected string
+	}{
+		{"/draft/", "<body><p>draft</p>" + draftBanner + "</body>"},
+		{"/post/", "<body><p>post</p></body>"},
+	}
+
+	for _, tc := range testcases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		h.ServeHTTP(rec, req)
+		if rec.Body.String() != tc.expected {
+			t.Fatalf("GET %s: got %q, expected %q", tc.path, rec.Body.String(), tc.expected)
+		}
+	}
+}
+
+func TestBuildIsDraft(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src/layout.tmpl":   "{{ .Current.Content }}",
+		"src/blog/a.md":     "+++\ntitle = \"a\"\n+++\n",
+		"src/blog/draft.md": "+++\ntitle = \"draft\"\ndraft = true\n+++\n",
+	})
+	t.Chdir(dir)
+
+	b := &Build{Drafts: true}
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		path     string
+		expected bool
+	}{
+		{"/blog/draft", true},
+		{"/blog/draft/", true},
+		{"/blog/draft/index.html", true},
+		{"/blog/a/", false},
+		{"/blog/", false},
+	}
+
+	for _, tc := range testcases {
+		if got := b.isDraft(tc.path); got != tc.expected {
+			t.Fatalf("isDraft(%q): got %t, expected %t", tc.path, got, tc.expected)
+		}
+	}
+
+	// Publishing the draft unmarks it in the next build.
+	writeTree(t, dir, map[string]string{"src/blog/draft.md": "+++\ntitle = \"draft\"\n+++\n"})
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if b.isDraft("/blog/draft/") {
+		t.Fatalf("isDraft(%q): got true after publishing, expected false", "/blog/draft/")
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"strings"
+)
+
+// WordsPerMinute is the reading speed used for Page.ReadingTime.
+const WordsPerMinute = 200
+
+// countWords returns the number of words in the text of the HTML. Tags
+// separate words, so that adjacent paragraphs are not joined.
+func countWords(h template.HTML) int {
+	text := html.UnescapeString(htmlTagRe.ReplaceAllString(string(h), " "))
+	return len(strings.Fields(text))
+}
+
+// readingTime returns the minutes needed to read the number of words at
+// WordsPerMinute, rounded up.
+func readingTime(words int) int {
+	return (words + WordsPerMinute - 1) / WordsPerMinute
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestCountWords(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		in       template.HTML
+		expected int
+	}{
+		{"<p>The quick <em>brown</em> fox jumps over the lazy dog.</p>", 9},
+		{"<h2 id=\"a\">Title</h2>\n<p>one&nbsp;two</p><p>three</p>", 4},
+		{"<pre><code>x := 1\n</code></pre>", 3},
+		{"", 0},
+	}
+
+	for _, tc := range testcases {
+		if got := countWords(tc.in); got != tc.expected {
+			t.Fatalf("countWords(%q): got %d, expected %d", tc.in, got, tc.expected)
+		}
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		words, expected int
+	}{
+		{0, 0},
+		{1, 1},
+		{200, 1},
+		{201, 2},
+		{1000, 5},
+	}
+
+	for _, tc := range testcases {
+		if got := readingTime(tc.words); got != tc.expected {
+			t.Fatalf("readingTime(%d): got %d, expected %d", tc.words, got, tc.expected)
+		}
+	}
+
+	words := template.HTML("<p>" + strings.Repeat("word ", 450) + "</p>")
+	if got := readingTime(countWords(words)); got != 3 {
+		t.Fatalf("readingTime: got %d, expected 3", got)
+	}
+}